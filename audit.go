@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().Bool("audit-log", false, "Annotate differing Cloudflare records with their last change from the Cloudflare audit log, and list the recent changes, with those of route53 when --cloudtrail is set")
+	viper.BindPFlag("audit-log", rootCmd.PersistentFlags().Lookup("audit-log"))
+
+	rootCmd.PersistentFlags().Duration("audit-since", 30*24*time.Hour, "How far back the Cloudflare audit log and CloudTrail are read")
+	viper.BindPFlag("audit-since", rootCmd.PersistentFlags().Lookup("audit-since"))
+}
+
+const (
+	// auditPageSize is the number of audit log entries read per request
+	auditPageSize = 100
+
+	// auditMaxPages bounds the audit log read for busy accounts
+	auditMaxPages = 10
+)
+
+// fetchAuditLog reads the audit log entries of a zone since the given time,
+// newest first, from the account when one is set and the user otherwise.
+func fetchAuditLog(cfg *config, z *zone, since time.Time) ([]cloudflare.AuditLog, error) {
+	endpoint := "/user/audit_logs"
+	if cfg.cfAccountID != "" {
+		endpoint = "/accounts/" + cfg.cfAccountID + "/audit_logs"
+	}
+
+	entries := make([]cloudflare.AuditLog, 0)
+	for page := 1; page <= auditMaxPages; page++ {
+		filter := cloudflare.AuditLogFilter{
+			ZoneName:  normalizeName(z.apex()),
+			Since:     since.UTC().Format(time.RFC3339),
+			Direction: "desc",
+			PerPage:   auditPageSize,
+			Page:      page,
+		}
+
+		raw, err := cfg.api.Raw("GET", endpoint+filter.String(), nil)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read the cloudflare audit log: %s", err)
+		}
+
+		result := make([]cloudflare.AuditLog, 0)
+		if err := json.Unmarshal(raw, &result); err != nil {
+			return nil, fmt.Errorf("Unable to read the cloudflare audit log: %s", err)
+		}
+
+		entries = append(entries, result...)
+		if len(result) < auditPageSize {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// describeAudit summarises an audit log entry for the timeline.
+func describeAudit(e cloudflare.AuditLog) string {
+	what := e.Resource.Type + " " + e.Resource.ID
+	if name, ok := e.Metadata["name"].(string); ok {
+		what = name
+		if typ, ok := e.Metadata["type"].(string); ok {
+			what += " " + typ
+		}
+	}
+
+	return fmt.Sprintf("%s cloudflare %s %s by %s", e.When.UTC().Format(time.RFC3339), e.Action.Type, what, e.Actor.Email)
+}
+
+// auditZone annotates the differences of a zone with the last change made
+// to their cloudflare records and lists the zone's recent cloudflare
+// changes. The route53 side comes from CloudTrail, see trailZone.
+func auditZone(cfg *config, z *zone) error {
+	status.setPhase(z.apex(), "reading the cloudflare audit log")
+
+	entries, err := fetchAuditLog(cfg, z, time.Now().Add(-viper.GetDuration("audit-since")))
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]string)
+	for _, r := range z.cfRecordSet {
+		keys[r.ID] = recordKey(r.Name, r.Type)
+	}
+
+	last := make(map[string]string)
+	for _, e := range entries {
+		z.timeline = append(z.timeline, describeAudit(e))
+
+		// entries are newest first
+		if k, ok := keys[e.Resource.ID]; ok && last[k] == "" {
+			last[k] = fmt.Sprintf("%s on %s", e.Actor.Email, e.When.Format(time.RFC3339))
+		}
+	}
+
+	for i, d := range z.diffs {
+		if d.Cloudflare != nil {
+			z.diffs[i].lastChange = last[recordKey(d.Name, d.Type)]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().String("cf-key-from", "", "Read the Cloudflare API Key from AWS: a Secrets Manager secret ARN, an SSM parameter ARN or ssm://name")
+	viper.BindPFlag("cf-key-from", rootCmd.PersistentFlags().Lookup("cf-key-from"))
+
+	rootCmd.PersistentFlags().String("cf-token-from", "", "Read the Cloudflare API Token from AWS, as --cf-key-from")
+	viper.BindPFlag("cf-token-from", rootCmd.PersistentFlags().Lookup("cf-token-from"))
+}
+
+// awsSecretCall is a JSON API call reading a secret, the SDK version
+// vendored here has no Secrets Manager or SSM client.
+type awsSecretCall struct {
+	service string
+	region  string
+	target  string
+	body    map[string]interface{}
+}
+
+// parseSecretRef works out the call reading a secret reference.
+func parseSecretRef(sess *session.Session, ref string) (awsSecretCall, error) {
+	c := awsSecretCall{region: aws.StringValue(sess.Config.Region)}
+
+	arn := strings.Split(ref, ":")
+	switch {
+	case strings.HasPrefix(ref, "arn:") && len(arn) >= 6 && arn[2] == "secretsmanager":
+		c.service, c.region = "secretsmanager", arn[3]
+		c.target = "secretsmanager.GetSecretValue"
+		c.body = map[string]interface{}{"SecretId": ref}
+	case strings.HasPrefix(ref, "arn:") && len(arn) >= 6 && arn[2] == "ssm":
+		c.service, c.region = "ssm", arn[3]
+		c.target = "AmazonSSM.GetParameter"
+		c.body = map[string]interface{}{"Name": ref, "WithDecryption": true}
+	case strings.HasPrefix(ref, "ssm://"):
+		c.service = "ssm"
+		c.target = "AmazonSSM.GetParameter"
+		c.body = map[string]interface{}{"Name": strings.TrimPrefix(ref, "ssm://"), "WithDecryption": true}
+	default:
+		return c, fmt.Errorf("Unknown secret reference '%s', use a secretsmanager or ssm ARN or ssm://name", ref)
+	}
+
+	if c.region == "" {
+		return c, fmt.Errorf("No AWS region to read '%s' from, use --aws-region", ref)
+	}
+
+	return c, nil
+}
+
+// awsJSONCall calls an AWS JSON 1.1 API with the credentials of the
+// session, decoding the response into out. It serves the APIs the vendored
+// SDK has no client for.
+func awsJSONCall(sess *session.Session, service, region, target string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("https://%s.%s.amazonaws.com/", service, region), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+
+	if _, err := v4.NewSigner(sess.Config.Credentials).Sign(req, bytes.NewReader(body), service, region, time.Now()); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(b, &e)
+		return fmt.Errorf("%s %s", e.Type, e.Message)
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+// awsRESTCall makes a GET request to an AWS REST XML API with the
+// credentials of the session, decoding the response into out.
+func awsRESTCall(sess *session.Session, service, region, url string, out interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := v4.NewSigner(sess.Config.Credentials).Sign(req, nil, service, region, time.Now()); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Code    string `xml:"Error>Code"`
+			Message string `xml:"Error>Message"`
+		}
+		xml.Unmarshal(b, &e)
+		return fmt.Errorf("%s %s", e.Code, e.Message)
+	}
+
+	return xml.Unmarshal(b, out)
+}
+
+// awsSecret reads a secret from AWS Secrets Manager or SSM Parameter Store
+// with the credentials of the session.
+func awsSecret(sess *session.Session, ref string) (string, error) {
+	c, err := parseSecretRef(sess, ref)
+	if err != nil {
+		return "", err
+	}
+
+	var out struct {
+		SecretString string
+		Parameter    struct {
+			Value string
+		}
+	}
+	if err := awsJSONCall(sess, c.service, c.region, c.target, c.body, &out); err != nil {
+		return "", fmt.Errorf("Unable to read '%s': %s", ref, err)
+	}
+
+	if c.service == "ssm" {
+		return out.Parameter.Value, nil
+	}
+	return out.SecretString, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cachedZone is the last successfully fetched cloudflare state of a zone.
+// It stands in for the live data when cloudflare is unavailable.
+type cachedZone struct {
+	Fetched time.Time `json:"fetched"`
+	ZoneID  string    `json:"zone_id"`
+	Records []record  `json:"records"`
+}
+
+func cachePath(dir, name string) string {
+	return filepath.Join(dir, strings.ToLower(name)+".json")
+}
+
+// saveCache stores the cloudflare records of a zone in dir.
+func saveCache(dir, name string, c *cachedZone) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(cachePath(dir, name), b, 0600)
+}
+
+// loadCache reads the cached cloudflare records of a zone from dir.
+func loadCache(dir, name string) (*cachedZone, error) {
+	b, err := ioutil.ReadFile(cachePath(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	c := &cachedZone{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
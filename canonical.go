@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	configSetting("canonicalize", false)
+}
+
+// canonicalizers undo the known ways the providers rewrite values of a
+// type, so the same record reads the same from both. They report whether
+// the value parsed, a value that doesn't is compared as given.
+var canonicalizers = map[string]func(string) (string, bool){
+	// route53 quotes, chunks and octal-escapes the text, cloudflare stores
+	// it bare
+	"TXT": canonicalTXT,
+	"SPF": canonicalTXT,
+
+	// cloudflare lowercases names and drops the trailing dot
+	"CNAME": canonicalName,
+	"NS":    canonicalName,
+	"PTR":   canonicalName,
+
+	// the preference, priority, weight and port are numbers, the target
+	// a name as above, or "." for no service
+	"MX": func(value string) (string, bool) {
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return value, false
+		}
+		return fields[0] + " " + canonicalTarget(fields[1]), true
+	},
+
+	"SRV": func(value string) (string, bool) {
+		fields := strings.Fields(value)
+		if len(fields) != 4 {
+			return value, false
+		}
+		return strings.Join(fields[:3], " ") + " " + canonicalTarget(fields[3]), true
+	},
+
+	// zero compression and case vary between writers
+	"AAAA": func(value string) (string, bool) {
+		if ip := net.ParseIP(value); ip != nil {
+			return ip.String(), true
+		}
+		return value, false
+	},
+
+	// quoting of the value and case of the tag vary between providers
+	"CAA": func(value string) (string, bool) {
+		if flags, tag, val, err := parseCAA(value); err == nil {
+			return fmt.Sprintf(`%d %s "%s"`, flags, tag, val), true
+		}
+		return value, false
+	},
+
+	// optional fields and precision vary between writers
+	"LOC": func(value string) (string, bool) {
+		if data, err := locData(value); err == nil {
+			return formatLOC(data), true
+		}
+		return value, false
+	},
+
+	"NAPTR": func(value string) (string, bool) {
+		if data, err := naptrData(value); err == nil {
+			return formatNAPTR(data), true
+		}
+		return value, false
+	},
+
+	// digests may be split and in either case
+	"DS": func(value string) (string, bool) {
+		if data, err := dsData(value); err == nil {
+			return formatDS(data), true
+		}
+		return value, false
+	},
+
+	// route53 takes mnemonics for the type and algorithm, cloudflare
+	// returns numbers
+	"CERT": func(value string) (string, bool) {
+		if data, err := certData(value); err == nil {
+			return formatCERT(data), true
+		}
+		return value, false
+	},
+
+	"SMIMEA": func(value string) (string, bool) {
+		if data, err := smimeaData(value); err == nil {
+			return formatSMIMEA(data), true
+		}
+		return value, false
+	},
+
+	"URI": func(value string) (string, bool) {
+		if priority, data, err := uriData(value); err == nil {
+			return formatURI(priority, data), true
+		}
+		return value, false
+	},
+}
+
+func canonicalTXT(value string) (string, bool) {
+	return quoteTXT(parseTXT(value)), true
+}
+
+func canonicalName(value string) (string, bool) {
+	return normalizeName(value), true
+}
+
+// canonicalTarget normalizes the target of an MX or SRV record, keeping
+// the root that stands for no service.
+func canonicalTarget(target string) string {
+	if target == "." {
+		return target
+	}
+	return normalizeName(target)
+}
+
+// canonicalRule is a rule from the canonicalize section of the config
+// file, for provider rewrites not covered by the built in table. Rules
+// apply to the values of both providers after the built in ones.
+//
+//	canonicalize:
+//	  - type: SSHFP
+//	    lowercase: true
+//	  - type: SRV
+//	    pattern: ^(\d+ \d+ \d+ )\.$
+//	    replace: ${1}none
+type canonicalRule struct {
+	// Type limits the rule to one record type, all types match when empty
+	Type string
+
+	Pattern   string
+	Replace   string
+	Lowercase bool
+
+	pattern *regexp.Regexp
+}
+
+// canonicalRules are the canonicalize rules of a run, in order.
+type canonicalRules []canonicalRule
+
+// loadCanonicalRules reads and compiles the canonicalize rules of the
+// config file.
+func loadCanonicalRules() (canonicalRules, error) {
+	rules := make(canonicalRules, 0)
+	if err := viper.UnmarshalKey("canonicalize", &rules); err != nil {
+		return nil, fmt.Errorf("Invalid canonicalize rules: %s", err)
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		r.Type = strings.ToUpper(r.Type)
+
+		var err error
+		if r.pattern, err = regexp.Compile(r.Pattern); err != nil {
+			return nil, fmt.Errorf("Invalid pattern in canonicalize rule %d: %s", i+1, err)
+		}
+		if r.Pattern == "" && !r.Lowercase {
+			return nil, fmt.Errorf("Canonicalize rule %d has neither a pattern nor lowercase", i+1)
+		}
+	}
+
+	return rules, nil
+}
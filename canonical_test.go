@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestCanonicalValue(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value string
+		want  string
+	}{
+		{"TXT", `"v=spf1 " "-all"`, `"v=spf1 -all"`},
+		{"TXT", "v=spf1 -all", `"v=spf1 -all"`},
+		{"TXT", "café", `"caf\303\251"`},
+		{"CNAME", "Web.Example.NET.", "web.example.net"},
+		{"NS", "ns1.example.org.", "ns1.example.org"},
+		{"MX", "10 MX1.example.com.", "10 mx1.example.com"},
+		{"MX", "0 .", "0 ."},
+		{"MX", "mx1.example.com.", "mx1.example.com"},
+		{"SRV", "10 5 5060 SIP.example.com.", "10 5 5060 sip.example.com"},
+		{"SRV", "0 0 0 .", "0 0 0 ."},
+		{"AAAA", "2001:0DB8:0000:0000:0000:0000:0000:0001", "2001:db8::1"},
+		{"CAA", `0 ISSUE "letsencrypt.org"`, `0 issue "letsencrypt.org"`},
+		{"CAA", "0 issue letsencrypt.org", `0 issue "letsencrypt.org"`},
+		{"CERT", "PGP 0 RSASHA256 AbCd", "3 0 8 AbCd"},
+		{"DS", "60485 13 2 d4b7 d520", "60485 13 2 D4B7D520"},
+		{"URI", `10 1 "https://example.com/"`, `10 1 "https://example.com/"`},
+		{"A", "192.0.2.1", "192.0.2.1"},
+		{"DS", "not a ds value.", "not a ds value"},
+	}
+
+	for _, tt := range tests {
+		if got := canonicalValue(tt.typ, tt.value); got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.typ, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalRules(t *testing.T) {
+	viper.Set("canonicalize", []map[string]interface{}{
+		{"type": "sshfp", "lowercase": true},
+		{"type": "SRV", "pattern": `^(\d+ \d+ \d+ )\.$`, "replace": "${1}none"},
+		{"pattern": "legacy-", "replace": ""},
+	})
+	defer viper.Set("canonicalize", nil)
+
+	rules, err := loadCanonicalRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		typ   string
+		value string
+		want  string
+	}{
+		{"SSHFP", "1 2 ABCDEF", "1 2 abcdef"},
+		{"TLSA", "3 1 1 ABCDEF", "3 1 1 ABCDEF"},
+		{"SRV", "0 0 0 .", "0 0 0 none"},
+		{"CNAME", "legacy-web.example.net.", "web.example.net"},
+	}
+	for _, tt := range tests {
+		if got := normalizeValue(rules, tt.typ, tt.value); got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.typ, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalRulesInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []map[string]interface{}
+	}{
+		{"bad pattern", []map[string]interface{}{{"pattern": "("}}},
+		{"no rewrite", []map[string]interface{}{{"type": "TXT"}}},
+	}
+
+	defer viper.Set("canonicalize", nil)
+	for _, tt := range tests {
+		viper.Set("canonicalize", tt.rules)
+		if _, err := loadCanonicalRules(); err == nil {
+			t.Errorf("%s: loaded, want an error", tt.name)
+		}
+	}
+}
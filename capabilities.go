@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(capabilitiesCmd)
+}
+
+var capabilitiesCmd = &cobra.Command{
+	Use:   "capabilities",
+	Short: "Print which Route53 record types and routing features migrate to Cloudflare",
+	Long: `Prints a matrix of the Route53 record types, routing policies and health
+check types against Cloudflare: supported, partially supported or
+unsupported. Each entry is worked out by running a sample through the same
+conversions migrate uses, so the matrix always matches this build.`,
+	Args: cobra.NoArgs,
+	Run:  doCapabilities,
+}
+
+// capability is a row of the matrix.
+type capability struct {
+	Feature string
+	Support string
+	Note    string
+}
+
+// typeSamples are a value of each route53 record type, converted to find
+// out what cloudflare takes.
+var typeSamples = []struct {
+	Type  string
+	Name  string
+	Value string
+}{
+	{"A", "www.example.com.", "192.0.2.1"},
+	{"AAAA", "www.example.com.", "2001:db8::1"},
+	{"CAA", "example.com.", `0 issue "ca.example.net"`},
+	{"CNAME", "www.example.com.", "target.example.net."},
+	{"DS", "sub.example.com.", "12345 13 2 8ABC3B4B8D0C0A5BD3EC3EC2D0B3C6E8A0A0B1B2B3B4B5B6B7B8B9BABBBCBDBE"},
+	{"MX", "example.com.", "10 mail.example.net."},
+	{"NAPTR", "example.com.", `100 10 "u" "E2U+sip" "!^.*$!sip:info@example.net!" .`},
+	{"NS", "sub.example.com.", "ns1.example.net."},
+	{"PTR", "1.2.0.192.in-addr.arpa.", "host.example.net."},
+	{"SOA", "example.com.", "ns1.example.net. hostmaster.example.com. 1 7200 900 1209600 86400"},
+	{"SPF", "example.com.", `"v=spf1 -all"`},
+	{"SRV", "_sip._tcp.example.com.", "10 5 5060 sip.example.net."},
+	{"TXT", "example.com.", `"v=spf1 -all"`},
+}
+
+// typeCapabilities probes the conversion of each record type.
+func typeCapabilities() []capability {
+	caps := make([]capability, 0, len(typeSamples))
+	for _, s := range typeSamples {
+		c := capability{Feature: s.Type + " records", Support: "supported"}
+
+		if reason, ok := unsupportedTypes[s.Type]; ok {
+			c.Support, c.Note = "unsupported", reason
+			caps = append(caps, c)
+			continue
+		}
+
+		switch s.Type {
+		case "SOA":
+			c.Support, c.Note = "not migrated", "cloudflare manages the SOA record of its zones"
+			caps = append(caps, c)
+			continue
+		case "NS":
+			c.Support, c.Note = "partial", "apex NS records are cloudflare's own, others delegate subdomains"
+		}
+
+		if _, err := toCloudflare(s.Name, s.Type, 300, s.Value); err != nil {
+			c.Support, c.Note = "unsupported", err.Error()
+		} else if _, ok := canonicalizers[s.Type]; ok && c.Note == "" {
+			c.Note = "compared after normalizing provider formatting"
+		}
+
+		caps = append(caps, c)
+	}
+
+	return caps
+}
+
+// routingCapabilities probes how routing policy record sets migrate.
+func routingCapabilities() []capability {
+	set := func(mod func(r *route53.ResourceRecordSet)) []*route53.ResourceRecordSet {
+		r := &route53.ResourceRecordSet{Name: aws.String("www.example.com."), Type: aws.String("A"), SetIdentifier: aws.String("one")}
+		mod(r)
+		return []*route53.ResourceRecordSet{r}
+	}
+	balanced := func(feature string, sets []*route53.ResourceRecordSet) capability {
+		if balanceable(sets) {
+			return capability{feature, "supported", "as a load balancer with --create-load-balancers"}
+		}
+		return capability{feature, "unsupported", "listed as a routing policy record"}
+	}
+
+	caps := []capability{
+		{"simple records", "supported", ""},
+		{"alias records", "partial", "converted to CNAME records, or to the target's addresses with --flatten-aliases"},
+		balanced("failover routing", set(func(r *route53.ResourceRecordSet) { r.Failover = aws.String("PRIMARY") })),
+		balanced("weighted routing", set(func(r *route53.ResourceRecordSet) { r.Weight = aws.Int64(10) })),
+		balanced("geolocation by continent", set(func(r *route53.ResourceRecordSet) {
+			r.GeoLocation = &route53.GeoLocation{ContinentCode: aws.String("EU")}
+		})),
+		balanced("geolocation by country", set(func(r *route53.ResourceRecordSet) {
+			r.GeoLocation = &route53.GeoLocation{CountryCode: aws.String("DE")}
+		})),
+		balanced("geolocation by subdivision", set(func(r *route53.ResourceRecordSet) {
+			r.GeoLocation = &route53.GeoLocation{CountryCode: aws.String("US"), SubdivisionCode: aws.String("CA")}
+		})),
+		balanced("latency routing", set(func(r *route53.ResourceRecordSet) { r.Region = aws.String("us-east-1") })),
+		balanced("multivalue answer routing", set(func(r *route53.ResourceRecordSet) { r.MultiValueAnswer = aws.Bool(true) })),
+		{"traffic policies", "unsupported", "their records are listed for manual action"},
+	}
+
+	for _, typ := range []string{"HTTP", "HTTPS", "HTTP_STR_MATCH", "HTTPS_STR_MATCH", "TCP", "CALCULATED", "CLOUDWATCH_METRIC"} {
+		c := capability{Feature: typ + " health checks", Support: "supported", Note: "as a load balancer monitor"}
+		if _, err := healthMonitor("", &route53.HealthCheckConfig{Type: aws.String(typ)}); err != nil {
+			c.Support, c.Note = "unsupported", err.Error()
+		}
+		caps = append(caps, c)
+	}
+
+	return caps
+}
+
+func doCapabilities(cmd *cobra.Command, args []string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ROUTE53\tCLOUDFLARE\tNOTES")
+	for _, c := range append(typeCapabilities(), routingCapabilities()...) {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Feature, c.Support, c.Note)
+	}
+	w.Flush()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// analyzeChains follows CNAME records pointing at other CNAME records of
+// the zone. Chains are listed in the zone's chains and, with flatten,
+// pointed straight at their final target. Loops never resolve and are
+// listed for manual action.
+func analyzeChains(z *zone, flatten bool) {
+	cnames := make(map[string]int)
+	for i, r := range z.awsRecordSet {
+		if r.Type == "CNAME" && len(r.Value) == 1 {
+			cnames[normalizeName(r.Name)] = i
+		}
+	}
+
+	for i, r := range z.awsRecordSet {
+		if r.Type != "CNAME" || len(r.Value) != 1 {
+			continue
+		}
+
+		hops := []string{strings.TrimSuffix(r.Name, "."), strings.TrimSuffix(r.Value[0], ".")}
+		seen := map[string]bool{normalizeName(r.Name): true}
+		proxied := r.Proxied
+		loop := false
+
+		target := normalizeName(r.Value[0])
+		for {
+			j, ok := cnames[target]
+			if !ok {
+				break
+			}
+			if seen[target] {
+				loop = true
+				break
+			}
+			seen[target] = true
+
+			next := z.awsRecordSet[j]
+			proxied = proxied || next.Proxied
+			hops = append(hops, strings.TrimSuffix(next.Value[0], "."))
+			target = normalizeName(next.Value[0])
+		}
+
+		chain := strings.Join(hops, " -> ")
+		switch {
+		case loop:
+			z.manual = append(z.manual, fmt.Sprintf("%s CNAME: loop %s never resolves", r.Name, chain))
+		case len(hops) > 2:
+			note := ""
+			if proxied {
+				note = ", proxied: cloudflare resolves the chain to the final target itself and the proxy settings of later hops don't apply"
+			}
+			if flatten {
+				z.awsRecordSet[i].Value = []string{hops[len(hops)-1] + "."}
+				note = ", flattened" + note
+			}
+			z.chains = append(z.chains, chain+note)
+		}
+	}
+}
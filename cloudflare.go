@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// fetchCloudflare looks up the cloudflare zone and its records, caching them
+// when a cache directory is configured.
+func fetchCloudflare(cfg *config, z *zone, name string) error {
+	// verify domain exists in cloudflare
+	status.setPhase(name, "finding cloudflare zone")
+	zoneID, err := cfg.api.ZoneIDByName(name)
+	if err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	z.zoneID = zoneID
+
+	// Fetch cloudflare record set
+	status.setPhase(name, "fetching cloudflare records")
+	records, err := cfg.api.DNSRecords(z.zoneID, cloudflare.DNSRecord{})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if (r.Type == "NS" || r.Type == "SOA") && equalNames(r.Name, name) {
+			continue
+		}
+		z.cfRecordSet = append(z.cfRecordSet, fromCloudflare(r))
+	}
+
+	if cfg.cacheDir != "" {
+		err := saveCache(cfg.cacheDir, name, &cachedZone{
+			Fetched: time.Now(),
+			ZoneID:  z.zoneID,
+			Records: z.cfRecordSet,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "WARNING: unable to cache cloudflare records for %s: %s\n", name, err)
+		}
+	}
+
+	return nil
+}
+
+// newCloudflare returns a cloudflare client authenticating with the API
+// token when there is one, the email and global API key otherwise. With an
+// account ID zone lookups and account level resources are scoped to it.
+func newCloudflare(cfg *config) (*cloudflare.API, error) {
+	opts := make([]cloudflare.Option, 0)
+	if cfg.cfAccountID != "" {
+		opts = append(opts, cloudflare.UsingOrganization(cfg.cfAccountID))
+	}
+
+	if cfg.cftoken == "" {
+		return cloudflare.New(cfg.cfkey, cfg.cfemail, opts...)
+	}
+
+	// this client version predates token support, the token is sent as a
+	// bearer header with the key based auth headers turned off
+	opts = append(opts, cloudflare.Headers(http.Header{
+		"Authorization": []string{"Bearer " + cfg.cftoken},
+	}))
+	api, err := cloudflare.NewWithUserServiceKey(cfg.cftoken, opts...)
+	if err != nil {
+		return nil, err
+	}
+	api.SetAuthType(0)
+
+	return api, nil
+}
+
+// printDelegation prints the NS records that must remain in the parent
+// route53 zone for a subdomain served by cloudflare.
+func printDelegation(cfg *config, z *zone) error {
+	details, err := cfg.api.ZoneDetails(z.zoneID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Delegation records to keep in route53 zone %s:\n", z.name)
+	for _, ns := range details.NameServers {
+		fmt.Printf("  %s. NS %s.\n", z.subdomain, strings.TrimSuffix(ns, "."))
+	}
+
+	if len(z.delegation) == 0 {
+		fmt.Printf("No delegation for %s currently exists in route53\n", z.subdomain)
+		return nil
+	}
+
+	if !equalNameSets(z.delegation, details.NameServers) {
+		fmt.Printf("Current route53 delegation differs: %s\n", strings.Join(z.delegation, ", "))
+	}
+
+	return nil
+}
+
+// fromCloudflare converts a cloudflare record into a record holding its
+// value in zone file presentation format, as route53 does.
+func fromCloudflare(r cloudflare.DNSRecord) record {
+	value := r.Content
+	switch r.Type {
+	case "MX":
+		value = fmt.Sprintf("%d %s", r.Priority, r.Content)
+	case "TXT", "SPF":
+		value = quoteTXT(parseTXT(r.Content))
+	case "CAA":
+		if data, ok := r.Data.(map[string]interface{}); ok {
+			value = fmt.Sprintf(`%v %v "%v"`, data["flags"], data["tag"], data["value"])
+		}
+	case "LOC":
+		if data, ok := r.Data.(map[string]interface{}); ok {
+			value = formatLOC(data)
+		}
+	case "NAPTR":
+		if data, ok := r.Data.(map[string]interface{}); ok {
+			value = formatNAPTR(data)
+		}
+	case "DS":
+		if data, ok := r.Data.(map[string]interface{}); ok {
+			value = formatDS(data)
+		}
+	case "CERT":
+		if data, ok := r.Data.(map[string]interface{}); ok {
+			value = formatCERT(data)
+		}
+	case "SMIMEA":
+		if data, ok := r.Data.(map[string]interface{}); ok {
+			value = formatSMIMEA(data)
+		}
+	case "URI":
+		if data, ok := r.Data.(map[string]interface{}); ok {
+			value = formatURI(r.Priority, data)
+		}
+	case "SRV":
+		// content holds "weight port target", the priority is separate
+		value = fmt.Sprintf("%d %s", r.Priority, strings.Join(strings.Fields(r.Content), " "))
+	}
+
+	return record{
+		ID:    r.ID,
+		Name:  r.Name,
+		Value: []string{value},
+		Type:  r.Type,
+		TTL:   r.TTL,
+
+		Proxied:  r.Proxied,
+		Modified: r.ModifiedOn,
+	}
+}
+
+// toCloudflare converts a single value of a record into a cloudflare record.
+func toCloudflare(name, typ string, ttl int, value string) (cloudflare.DNSRecord, error) {
+	rr := cloudflare.DNSRecord{
+		Name:    toASCIIName(strings.TrimSuffix(name, ".")),
+		Type:    typ,
+		TTL:     ttl,
+		Content: value,
+	}
+
+	if validate, ok := validators[typ]; ok {
+		if err := validate(value); err != nil {
+			return rr, err
+		}
+	}
+
+	switch typ {
+	case "CNAME", "NS", "PTR":
+		rr.Content = toASCIIName(strings.TrimSuffix(value, "."))
+	case "TXT", "SPF":
+		// cloudflare takes the bare text and chunks it itself
+		rr.Content = parseTXT(value)
+	case "MX":
+		// route53 keeps the priority in the value, cloudflare in a field
+		fields := strings.Fields(value)
+		if len(fields) != 2 {
+			return rr, fmt.Errorf("Invalid MX value '%s'", value)
+		}
+
+		priority, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return rr, fmt.Errorf("Invalid MX priority in '%s'", value)
+		}
+
+		rr.Priority = priority
+		rr.Content = strings.TrimSuffix(fields[1], ".")
+		if fields[1] == "." {
+			// the null MX of RFC 7505 keeps its root target
+			rr.Content = "."
+		}
+	case "CAA":
+		flags, tag, val, err := parseCAA(value)
+		if err != nil {
+			return rr, err
+		}
+
+		rr.Content = ""
+		rr.Data = map[string]interface{}{
+			"flags": flags,
+			"tag":   tag,
+			"value": val,
+		}
+	case "SRV":
+		data, err := srvData(rr.Name, value)
+		if err != nil {
+			return rr, err
+		}
+
+		rr.Content = ""
+		rr.Data = data
+	case "URI":
+		priority, data, err := uriData(value)
+		if err != nil {
+			return rr, err
+		}
+
+		rr.Content = ""
+		rr.Priority = priority
+		rr.Data = data
+	default:
+		if parse, ok := dataParsers[typ]; ok {
+			data, err := parse(value)
+			if err != nil {
+				return rr, err
+			}
+
+			rr.Content = ""
+			rr.Data = data
+		}
+	}
+
+	return rr, nil
+}
+
+// srvData builds the structured data cloudflare requires for SRV records
+// from the record name (_service._proto.name) and the route53 value
+// (priority weight port target).
+func srvData(name, value string) (map[string]interface{}, error) {
+	labels := strings.SplitN(name, ".", 3)
+	if len(labels) != 3 || !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+		return nil, fmt.Errorf("SRV name '%s' is not of the form _service._proto.name", name)
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return nil, fmt.Errorf("Invalid SRV value '%s'", value)
+	}
+
+	numbers := make([]int, 3)
+	for i := range numbers {
+		n, err := strconv.Atoi(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid SRV value '%s'", value)
+		}
+		numbers[i] = n
+	}
+
+	return map[string]interface{}{
+		"service":  labels[0],
+		"proto":    labels[1],
+		"name":     labels[2],
+		"priority": numbers[0],
+		"weight":   numbers[1],
+		"port":     numbers[2],
+		"target":   strings.TrimSuffix(fields[3], "."),
+	}, nil
+}
+
+// parseCAA splits a CAA value (flags tag "value") into its parts, the value
+// may or may not be quoted.
+func parseCAA(value string) (int, string, string, error) {
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(fields) != 3 {
+		return 0, "", "", fmt.Errorf("Invalid CAA value '%s'", value)
+	}
+
+	flags, err := strconv.Atoi(fields[0])
+	if err != nil || flags < 0 || flags > 255 {
+		return 0, "", "", fmt.Errorf("Invalid CAA flags in '%s'", value)
+	}
+
+	val := strings.TrimSpace(fields[2])
+	if len(val) >= 2 && strings.HasPrefix(val, `"`) && strings.HasSuffix(val, `"`) {
+		val = val[1 : len(val)-1]
+	}
+
+	return flags, strings.ToLower(fields[1]), val, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().Bool("check-cloudfront", false, "Report CNAME records to CloudFront whose name is not an alternate domain name of the distribution, which CloudFront refuses with 403")
+	viper.BindPFlag("check-cloudfront", rootCmd.PersistentFlags().Lookup("check-cloudfront"))
+}
+
+// cloudfrontAPI is the endpoint and version of the CloudFront API, a
+// global service signed in us-east-1.
+const cloudfrontAPI = "https://cloudfront.amazonaws.com/2019-03-26"
+
+// cloudfrontDistribution is a distribution with its alternate domain names.
+type cloudfrontDistribution struct {
+	ID         string   `xml:"Id"`
+	DomainName string   `xml:"DomainName"`
+	Aliases    []string `xml:"Aliases>Items>CNAME"`
+}
+
+// cloudfrontDistributions caches the distributions of the account, read
+// once for all zones.
+var cloudfrontDistributions map[string]cloudfrontDistribution
+
+// fetchDistributions reads the distributions of the account by domain name.
+func fetchDistributions(cfg *config) (map[string]cloudfrontDistribution, error) {
+	if cloudfrontDistributions != nil {
+		return cloudfrontDistributions, nil
+	}
+
+	distributions := make(map[string]cloudfrontDistribution)
+	marker := ""
+	for {
+		var out struct {
+			IsTruncated bool                     `xml:"IsTruncated"`
+			NextMarker  string                   `xml:"NextMarker"`
+			Items       []cloudfrontDistribution `xml:"Items>DistributionSummary"`
+		}
+		u := cloudfrontAPI + "/distribution?MaxItems=100"
+		if marker != "" {
+			u += "&Marker=" + url.QueryEscape(marker)
+		}
+		if err := awsRESTCall(cfg.session, "cloudfront", "us-east-1", u, &out); err != nil {
+			return nil, fmt.Errorf("Unable to read CloudFront distributions: %s", err)
+		}
+
+		for _, d := range out.Items {
+			distributions[strings.ToLower(d.DomainName)] = d
+		}
+
+		if !out.IsTruncated {
+			break
+		}
+		marker = out.NextMarker
+	}
+
+	cloudfrontDistributions = distributions
+	return distributions, nil
+}
+
+// servesName reports whether a distribution has an alternate domain name,
+// possibly a wildcard, covering a name.
+func (d cloudfrontDistribution) servesName(name string) bool {
+	name = strings.ToLower(normalizeName(name))
+	for _, a := range d.Aliases {
+		if ok, _ := path.Match(strings.ToLower(a), name); ok && strings.Count(a, ".") == strings.Count(name, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCloudFront reports the CNAME records pointing at a distribution of
+// the account that doesn't list their name as an alternate domain name.
+// CloudFront refuses such requests, whether they come straight from
+// clients or through the cloudflare proxy, which passes the name on as
+// the Host header. Distributions of other accounts can't be checked.
+func checkCloudFront(cfg *config, z *zone) error {
+	status.setPhase(z.apex(), "reading CloudFront distributions")
+
+	var distributions map[string]cloudfrontDistribution
+	for _, r := range z.awsRecordSet {
+		target := strings.ToLower(normalizeName(r.Value[0]))
+		if r.Type != "CNAME" || !strings.HasSuffix(target, ".cloudfront.net") {
+			continue
+		}
+
+		if distributions == nil {
+			var err error
+			if distributions, err = fetchDistributions(cfg); err != nil {
+				return err
+			}
+		}
+
+		d, ok := distributions[target]
+		if !ok || d.servesName(r.Name) {
+			continue
+		}
+
+		proxied := ""
+		if r.Proxied {
+			proxied = ", proxied through cloudflare"
+		}
+		z.problems = append(z.problems, fmt.Sprintf("route53 %s: CNAME to CloudFront distribution %s%s, which does not list it as an alternate domain name and will answer 403", normalizeName(r.Name), d.ID, proxied))
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().Bool("cloudtrail", false, "Annotate differing Route53 records with their last change from CloudTrail ChangeResourceRecordSets events, read as far back as --audit-since, and list the recent changes")
+	viper.BindPFlag("cloudtrail", rootCmd.PersistentFlags().Lookup("cloudtrail"))
+}
+
+const (
+	// trailRegion is where CloudTrail logs the events of global services
+	// such as route53
+	trailRegion = "us-east-1"
+
+	// trailMaxPages bounds the events read, LookupEvents returns at most
+	// 50 per page and allows two requests a second
+	trailMaxPages = 20
+)
+
+// trailChange is a record set changed by a ChangeResourceRecordSets event.
+type trailChange struct {
+	zoneID string
+	action string
+	key    string
+	by     string
+	when   time.Time
+}
+
+// trailChanges caches the changes read from CloudTrail, the events of all
+// hosted zones come from the same lookup.
+var trailChanges []trailChange
+
+// fetchTrail reads the record set changes of all hosted zones since the
+// given time, newest first.
+func fetchTrail(cfg *config, since time.Time) ([]trailChange, error) {
+	if trailChanges != nil {
+		return trailChanges, nil
+	}
+
+	changes := make([]trailChange, 0)
+	token := ""
+	for page := 1; page <= trailMaxPages; page++ {
+		in := map[string]interface{}{
+			"LookupAttributes": []map[string]string{{"AttributeKey": "EventName", "AttributeValue": "ChangeResourceRecordSets"}},
+			"StartTime":        since.Unix(),
+			"MaxResults":       50,
+		}
+		if token != "" {
+			in["NextToken"] = token
+		}
+
+		var out struct {
+			Events []struct {
+				EventTime       float64
+				Username        string
+				CloudTrailEvent string
+			}
+			NextToken string
+		}
+		if err := awsJSONCall(cfg.session, "cloudtrail", trailRegion, "com.amazonaws.cloudtrail.v20131101.CloudTrail_20131101.LookupEvents", in, &out); err != nil {
+			return nil, fmt.Errorf("Unable to read CloudTrail events: %s", err)
+		}
+
+		for _, e := range out.Events {
+			var event struct {
+				RequestParameters struct {
+					HostedZoneID string `json:"hostedZoneId"`
+					ChangeBatch  struct {
+						Changes []struct {
+							Action            string `json:"action"`
+							ResourceRecordSet struct {
+								Name string `json:"name"`
+								Type string `json:"type"`
+							} `json:"resourceRecordSet"`
+						} `json:"changes"`
+					} `json:"changeBatch"`
+				} `json:"requestParameters"`
+			}
+			if err := json.Unmarshal([]byte(e.CloudTrailEvent), &event); err != nil {
+				continue
+			}
+
+			when := time.Unix(int64(e.EventTime), 0).UTC()
+			for _, c := range event.RequestParameters.ChangeBatch.Changes {
+				changes = append(changes, trailChange{
+					zoneID: strings.TrimPrefix(event.RequestParameters.HostedZoneID, "/hostedzone/"),
+					action: c.Action,
+					key:    recordKey(c.ResourceRecordSet.Name, c.ResourceRecordSet.Type),
+					by:     e.Username,
+					when:   when,
+				})
+			}
+		}
+
+		if out.NextToken == "" {
+			break
+		}
+		token = out.NextToken
+
+		// stay within the request rate of LookupEvents
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	trailChanges = changes
+	return changes, nil
+}
+
+// trailZone annotates the differences of a zone with the last change made
+// to their route53 records, as CloudTrail logged it, and adds the zone's
+// recent route53 changes to its timeline.
+func trailZone(cfg *config, z *zone) error {
+	status.setPhase(z.apex(), "reading CloudTrail")
+
+	changes, err := fetchTrail(cfg, time.Now().Add(-viper.GetDuration("audit-since")))
+	if err != nil {
+		return err
+	}
+
+	zoneID := strings.TrimPrefix(z.hostedZoneID, "/hostedzone/")
+	last := make(map[string]string)
+	for _, c := range changes {
+		if c.zoneID != zoneID {
+			continue
+		}
+		z.timeline = append(z.timeline, fmt.Sprintf("%s route53 %s %s by %s", c.when.Format(time.RFC3339), c.action, c.key, c.by))
+
+		// events are newest first
+		if last[c.key] == "" {
+			last[c.key] = fmt.Sprintf("%s on %s", c.by, c.when.Format(time.RFC3339))
+		}
+	}
+
+	// the timestamps in UTC order the changes of both providers
+	sort.Sort(sort.Reverse(sort.StringSlice(z.timeline)))
+
+	for i, d := range z.diffs {
+		if d.Route53 != nil {
+			z.diffs[i].route53Change = last[recordKey(d.Name, d.Type)]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	configSetting("github-token", true)
+	configSetting("github-url", false)
+	configSetting("gitlab-token", true)
+	configSetting("gitlab-url", false)
+}
+
+const (
+	// collapseLines is the size of a zone diff above which it is rendered
+	// collapsed in the comment
+	collapseLines = 15
+
+	// maxCommentSize keeps comments below the GitHub limit of 65536
+	// characters, GitLab allows more
+	maxCommentSize = 60000
+)
+
+// diffLines renders the differences of a zone as lines of a diff, where +
+// is what route53 has and - what cloudflare has. The last route53 change
+// of a set follows its lines as a context line.
+func diffLines(diffs []recordDiff) []string {
+	lines := make([]string, 0)
+	for _, d := range diffs {
+		lines = append(lines, setDiffLines(d)...)
+		if d.route53Change != "" {
+			lines = append(lines, fmt.Sprintf("  %s %s last changed in route53 by %s", d.Name, d.Type, d.route53Change))
+		}
+	}
+
+	return lines
+}
+
+// setDiffLines renders the differences of one record set.
+func setDiffLines(d recordDiff) []string {
+	lines := make([]string, 0)
+	if d.Route53 == nil || d.Cloudflare == nil {
+		if d.Cloudflare != nil {
+			lines = append(lines, fmt.Sprintf("- %s %s %s", d.Name, d.Type, formatRecord(d.canonical, d.Cloudflare)))
+		}
+		if d.Route53 != nil {
+			lines = append(lines, fmt.Sprintf("+ %s %s %s", d.Name, d.Type, formatRecord(d.canonical, d.Route53)))
+		}
+		return lines
+	}
+
+	// list values of multi-value sets individually
+	missing, extra := splitValues(d.canonical, d.Type, d.Route53.Value, d.Cloudflare.Value)
+	for _, v := range extra {
+		lines = append(lines, fmt.Sprintf("- %s %s %s %s", d.Name, d.Type, formatTTL(d.Cloudflare.TTL), v))
+	}
+	for _, v := range missing {
+		lines = append(lines, fmt.Sprintf("+ %s %s %s %s", d.Name, d.Type, formatTTL(d.Route53.TTL), v))
+	}
+	if d.ttlChanged() {
+		lines = append(lines, fmt.Sprintf("- %s %s ttl %s", d.Name, d.Type, formatTTL(d.Cloudflare.TTL)))
+		lines = append(lines, fmt.Sprintf("+ %s %s ttl %s", d.Name, d.Type, formatTTL(d.Route53.TTL)))
+	}
+	if d.proxyChanged() {
+		lines = append(lines, fmt.Sprintf("- %s %s %s", d.Name, d.Type, formatProxied(d.Cloudflare.Proxied)))
+		lines = append(lines, fmt.Sprintf("+ %s %s %s", d.Name, d.Type, formatProxied(d.Route53.Proxied)))
+	}
+
+	return lines
+}
+
+// renderComment renders the compare results as markdown suited to a pull
+// request comment, the summary first and large zone diffs collapsed.
+func renderComment(zones []*zone) string {
+	var missing, extra, different, matching int
+	for _, z := range zones {
+		matching += z.matching
+		for _, d := range z.diffs {
+			switch {
+			case d.Cloudflare == nil:
+				missing++
+			case d.Route53 == nil:
+				extra++
+			default:
+				different++
+			}
+		}
+	}
+
+	b := &bytes.Buffer{}
+	if group := viper.GetString("group"); group != "" {
+		fmt.Fprintf(b, "### cfmigrate compare of group %s\n\n", group)
+	} else {
+		fmt.Fprintf(b, "### cfmigrate compare\n\n")
+	}
+	fmt.Fprintf(b, "**%d zones, %d differences** (%d missing in Cloudflare, %d only in Cloudflare, %d different, %d matching)\n\n",
+		len(zones), missing+extra+different, missing, extra, different, matching)
+
+	fmt.Fprintf(b, "| Zone | Matching | Differences | Notes |\n|---|---|---|---|\n")
+	for _, z := range zones {
+		notes := make([]string, 0)
+		if !z.stale.IsZero() {
+			notes = append(notes, "stale cloudflare data from "+z.stale.Format(time.RFC3339))
+		}
+		if len(z.conversions) > 0 {
+			notes = append(notes, fmt.Sprintf("%d converted aliases", len(z.conversions)))
+		}
+		if len(z.transformed) > 0 {
+			notes = append(notes, fmt.Sprintf("%d transformed records", len(z.transformed)))
+		}
+		if len(z.problems) > 0 {
+			notes = append(notes, fmt.Sprintf("%d record problems", len(z.problems)))
+		}
+		if len(z.manual) > 0 {
+			notes = append(notes, fmt.Sprintf("%d need manual action", len(z.manual)))
+		}
+		if len(z.routed) > 0 {
+			notes = append(notes, fmt.Sprintf("%d routing policy records", len(z.routed)))
+		}
+		if len(z.subzones) > 0 {
+			notes = append(notes, fmt.Sprintf("%d delegated subdomains", len(z.subzones)))
+		}
+		fmt.Fprintf(b, "| %s | %d | %d | %s |\n", z.apex(), z.matching, len(z.diffs), strings.Join(notes, ", "))
+	}
+
+	for _, z := range zones {
+		lines := diffLines(z.diffs)
+		if len(lines) == 0 && len(z.conversions) == 0 && len(z.manual) == 0 && len(z.timeline) == 0 {
+			continue
+		}
+
+		section := &bytes.Buffer{}
+		open := ""
+		if len(lines) <= collapseLines {
+			open = " open"
+		}
+		fmt.Fprintf(section, "\n<details%s><summary>%s: %d differences</summary>\n\n", open, z.apex(), len(z.diffs))
+
+		if len(lines) > 0 {
+			fmt.Fprintf(section, "```diff\n%s\n```\n", strings.Join(lines, "\n"))
+		}
+
+		if len(z.conversions) > 0 {
+			fmt.Fprintf(section, "\nConverted alias records:\n\n")
+			for _, c := range z.conversions {
+				fmt.Fprintf(section, "- `%s`\n", c)
+			}
+		}
+
+		if len(z.manual) > 0 {
+			fmt.Fprintf(section, "\nNeeds manual action:\n\n")
+			for _, m := range z.manual {
+				fmt.Fprintf(section, "- `%s`\n", m)
+			}
+		}
+
+		if len(z.timeline) > 0 {
+			fmt.Fprintf(section, "\nRecent changes:\n\n")
+			for _, t := range z.timeline {
+				fmt.Fprintf(section, "- `%s`\n", t)
+			}
+		}
+
+		fmt.Fprintf(section, "\n</details>\n")
+
+		if b.Len()+section.Len() > maxCommentSize {
+			fmt.Fprintf(b, "\n_Output truncated, run `cfmigrate --domain %s` for the full diff._\n", z.apex())
+			break
+		}
+		b.Write(section.Bytes())
+	}
+
+	return b.String()
+}
+
+// postComment posts the comment to the configured GitHub pull request or
+// GitLab merge request, if any.
+func postComment(body string) error {
+	var endpoint string
+	header := make(http.Header)
+
+	switch {
+	case viper.GetString("github-pr") != "":
+		repo, number, err := splitReference(viper.GetString("github-pr"))
+		if err != nil {
+			return err
+		}
+
+		token, err := secretValue("github-token")
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return errors.New("No GitHub token supplied")
+		}
+
+		api := viper.GetString("github-url")
+		if api == "" {
+			api = "https://api.github.com"
+		}
+
+		endpoint = fmt.Sprintf("%s/repos/%s/issues/%s/comments", api, repo, number)
+		header.Set("Authorization", "token "+token)
+	case viper.GetString("gitlab-mr") != "":
+		project, number, err := splitReference(viper.GetString("gitlab-mr"))
+		if err != nil {
+			return err
+		}
+
+		token, err := secretValue("gitlab-token")
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return errors.New("No GitLab token supplied")
+		}
+
+		api := viper.GetString("gitlab-url")
+		if api == "" {
+			api = "https://gitlab.com/api/v4"
+		}
+
+		endpoint = fmt.Sprintf("%s/projects/%s/merge_requests/%s/notes", api, url.PathEscape(project), number)
+		header.Set("PRIVATE-TOKEN", token)
+	default:
+		return nil
+	}
+
+	payload, err := json.Marshal(map[string]string{"body": body})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Posting comment failed: %s: %s", resp.Status, msg)
+	}
+
+	return nil
+}
+
+// splitReference splits "owner/repo#123" into the repository and number.
+func splitReference(ref string) (string, string, error) {
+	i := strings.LastIndex(ref, "#")
+	if i <= 0 || i == len(ref)-1 {
+		return "", "", fmt.Errorf("Invalid reference '%s', expected project#number", ref)
+	}
+
+	return ref[:i], ref[i+1:], nil
+}
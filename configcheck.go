@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with the config file",
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config file for unknown keys, wrong types and invalid rules",
+	Long: `Checks the config file in use: YAML syntax, settings that match no flag or
+config section, values of the wrong type, and the transforms, canonicalize
+rules, proxied patterns, TTL overrides, tag policies, zone groups, profiles
+and email recipients. Each problem is reported with its line. The defaults of zone
+groups and the settings of profiles are checked like top level settings.`,
+	Args: cobra.NoArgs,
+	Run:  doConfigValidate,
+}
+
+// configKeys are the config file keys that aren't flags, registered with
+// configSetting by the files reading them.
+var configKeys = make(map[string]bool)
+
+// configSetting registers a config file key that isn't a flag. The value
+// of a secret, read with secretValue, may also come from a file named by
+// the key with a -file suffix.
+func configSetting(key string, secret bool) {
+	configKeys[key] = true
+	if secret {
+		configKeys[key+"-file"] = true
+	}
+}
+
+// configFile is the layout of the config file, strictly decoded so fields
+// unknown to the sections are reported.
+type configFile struct {
+	Transforms      []transform                       `yaml:"transforms"`
+	Canonicalize    []canonicalRule                   `yaml:"canonicalize"`
+	Groups          map[string]zoneGroup              `yaml:"groups"`
+	EmailRecipients map[string][]string               `yaml:"email-recipients"`
+	Profiles        map[string]map[string]interface{} `yaml:"profiles"`
+	TagPolicies     []tagPolicy                       `yaml:"tag-policies"`
+	Settings        map[string]interface{}            `yaml:",inline"`
+}
+
+// allFlags returns the flags of every command by name.
+func allFlags() map[string]*pflag.Flag {
+	flags := make(map[string]*pflag.Flag)
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		add := func(f *pflag.Flag) { flags[f.Name] = f }
+		cmd.PersistentFlags().VisitAll(add)
+		cmd.Flags().VisitAll(add)
+		for _, c := range cmd.Commands() {
+			walk(c)
+		}
+	}
+	walk(rootCmd)
+
+	return flags
+}
+
+// keyLine returns the line of a key path in YAML text, or of the deepest
+// parent found.
+func keyLine(lines []string, keys ...string) int {
+	line, start, indent := 0, 0, -1
+	for _, key := range keys {
+		child := -1
+		found := false
+		for i := start; i < len(lines); i++ {
+			trimmed := strings.TrimLeft(lines[i], " ")
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+
+			in := len(lines[i]) - len(trimmed)
+			if in <= indent {
+				break
+			}
+			if child == -1 {
+				child = in
+			}
+
+			parts := strings.SplitN(trimmed, ":", 2)
+			if in == child && len(parts) == 2 && strings.Trim(parts[0], `"' `) == key {
+				line, start, indent, found = i+1, i+1, in, true
+				break
+			}
+		}
+
+		if !found {
+			break
+		}
+	}
+
+	return line
+}
+
+// checkSetting checks a value against the type of the flag it sets.
+func checkSetting(f *pflag.Flag, v interface{}) error {
+	scalar := func(v interface{}) bool {
+		switch v.(type) {
+		case []interface{}, map[interface{}]interface{}:
+			return false
+		}
+		return true
+	}
+
+	switch f.Value.Type() {
+	case "bool":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("'%s' must be true or false", f.Name)
+		}
+	case "int", "int64", "uint":
+		if _, ok := v.(int); !ok {
+			return fmt.Errorf("'%s' must be a whole number", f.Name)
+		}
+	case "duration":
+		if s, ok := v.(string); ok {
+			if _, err := time.ParseDuration(s); err != nil {
+				return fmt.Errorf("'%s' must be a duration such as 10m: %s", f.Name, err)
+			}
+		} else if _, ok := v.(int); !ok {
+			return fmt.Errorf("'%s' must be a duration such as 10m", f.Name)
+		}
+	case "stringSlice", "stringArray":
+		if list, ok := v.([]interface{}); ok {
+			for _, item := range list {
+				if !scalar(item) {
+					return fmt.Errorf("'%s' must be a list of values", f.Name)
+				}
+			}
+		} else if !scalar(v) {
+			return fmt.Errorf("'%s' must be a list of values", f.Name)
+		}
+	default:
+		if !scalar(v) {
+			return fmt.Errorf("'%s' must be a single value", f.Name)
+		}
+	}
+
+	return nil
+}
+
+// validateConfig returns the problems of a config file, prefixed with
+// their lines.
+func validateConfig(b []byte) []string {
+	lines := strings.Split(string(b), "\n")
+	problems := make([]string, 0)
+	report := func(line int, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("line %d: %s", line, fmt.Sprintf(format, args...)))
+	}
+
+	cf := configFile{}
+	if err := yaml.UnmarshalStrict(b, &cf); err != nil {
+		if terr, ok := err.(*yaml.TypeError); ok {
+			// the messages start with their lines
+			problems = append(problems, terr.Errors...)
+		} else {
+			return append(problems, err.Error())
+		}
+	}
+
+	flags := allFlags()
+	checkSettings := func(settings map[string]interface{}, parents ...string) {
+		keys := make([]string, 0, len(settings))
+		for k := range settings {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			line := keyLine(lines, append(parents, k)...)
+			f, ok := flags[k]
+			switch {
+			case ok:
+				if err := checkSetting(f, settings[k]); err != nil {
+					report(line, "%s", err)
+				}
+			case !configKeys[k]:
+				report(line, "unknown setting '%s'", k)
+			}
+		}
+	}
+
+	checkSettings(cf.Settings)
+
+	for name, g := range cf.Groups {
+		if len(g.Domains) == 0 {
+			report(keyLine(lines, "groups", name), "zone group '%s' has no domains", name)
+		}
+		if _, ok := g.Defaults["group"]; ok {
+			report(keyLine(lines, "groups", name, "defaults", "group"), "zone group defaults can not set the group")
+		}
+		if _, ok := g.Defaults["domain"]; ok {
+			report(keyLine(lines, "groups", name, "defaults", "domain"), "zone group defaults can not set the domain")
+		}
+		checkSettings(g.Defaults, "groups", name, "defaults")
+	}
+
+	for name, settings := range cf.Profiles {
+		if _, ok := settings["profile"]; ok {
+			report(keyLine(lines, "profiles", name, "profile"), "profiles can not select other profiles")
+		}
+		delete(settings, "profiles")
+		checkSettings(settings, "profiles", name)
+	}
+
+	for pattern := range cf.EmailRecipients {
+		if _, err := path.Match(pattern, ""); err != nil {
+			report(keyLine(lines, "email-recipients", pattern), "invalid domain pattern '%s': %s", pattern, err)
+		}
+	}
+
+	// the rules are checked as they are loaded for a run
+	checks := []struct {
+		key   string
+		check func() error
+	}{
+		{"transforms", func() error { _, err := loadTransforms(); return err }},
+		{"tag-policies", func() error { _, err := loadTagPolicies(); return err }},
+		{"canonicalize", func() error { _, err := loadCanonicalRules(); return err }},
+		{"proxied", func() error { _, err := parseProxyRules(viper.GetStringSlice("proxied")); return err }},
+		{"ttl-override", func() error {
+			_, err := parseTTLPolicy(viper.GetInt("min-ttl"), viper.GetStringSlice("ttl-override"))
+			return err
+		}},
+	}
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			report(keyLine(lines, c.key), "%s", err)
+		}
+	}
+
+	return problems
+}
+
+func doConfigValidate(cmd *cobra.Command, args []string) {
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		checkErr(errors.New("No config file found"))
+	}
+	if ext := filepath.Ext(file); ext != ".yaml" && ext != ".yml" {
+		checkErr(fmt.Errorf("Only YAML config files can be validated, not %s", file))
+	}
+
+	b, err := ioutil.ReadFile(file)
+	checkErr(err)
+
+	if viper.InConfig("sops") {
+		b, err = sopsDecrypt(file)
+		checkErr(err)
+	}
+
+	problems := validateConfig(b)
+	if len(problems) == 0 {
+		fmt.Printf("%s is valid\n", file)
+		return
+	}
+
+	for _, p := range problems {
+		fmt.Printf("%s: %s\n", file, p)
+	}
+	checkErr(fmt.Errorf("%d config file problems found", len(problems)))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   []string
+	}{
+		{"valid", "min-ttl: 300\ndry-run: true\nproxied: [\"www\"]\ntransforms:\n  - name: ^old$\n    rename: new\n", []string{}},
+		{"unknown setting", "min-ttl: 300\nminttl: 300\n", []string{"line 2: unknown setting 'minttl'"}},
+		{"wrong types", "min-ttl: soon\ndry-run: maybe\nheartbeat: [10m]\n", []string{
+			"line 2: 'dry-run' must be true or false",
+			"line 3: 'heartbeat' must be a duration such as 10m",
+			"line 1: 'min-ttl' must be a whole number",
+		}},
+		{"unknown rule field", "transforms:\n  - name: ^a$\n    rename: b\n    renmae: c\n", []string{
+			"line 4: field renmae not found in type main.transform",
+		}},
+		{"invalid rule", "canonicalize:\n  - type: TXT\n", []string{
+			"line 1: Canonicalize rule 1 has neither a pattern nor lowercase",
+		}},
+		{"zone groups", "groups:\n  prod:\n    defaults:\n      domain: example.com\n      min-ttl: x\n", []string{
+			"line 2: zone group 'prod' has no domains",
+			"line 4: zone group defaults can not set the domain",
+			"line 5: 'min-ttl' must be a whole number",
+		}},
+		{"profiles", "profiles:\n  staging:\n    profile: prod\n    awskey: x\n", []string{
+			"line 3: profiles can not select other profiles",
+		}},
+		{"email recipients", "email-recipients:\n  \"[\": [ops@example.com]\n", []string{
+			"line 2: invalid domain pattern '[': syntax error in pattern",
+		}},
+	}
+
+	viper.SetConfigType("yaml")
+	defer viper.ReadConfig(bytes.NewReader(nil))
+	for _, tt := range tests {
+		// the rules are checked as loaded from viper
+		if err := viper.ReadConfig(bytes.NewBufferString(tt.config)); err != nil {
+			t.Fatal(err)
+		}
+		if got := validateConfig([]byte(tt.config)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestKeyLine(t *testing.T) {
+	lines := []string{
+		"# settings",
+		"min-ttl: 300",
+		"groups:",
+		"  prod:",
+		"    domains: [example.com]",
+		"    defaults:",
+		"      min-ttl: 60",
+		"  staging:",
+		"    domains: [example.org]",
+	}
+
+	tests := []struct {
+		keys []string
+		want int
+	}{
+		{[]string{"min-ttl"}, 2},
+		{[]string{"groups", "prod", "defaults", "min-ttl"}, 7},
+		{[]string{"groups", "staging", "domains"}, 9},
+		{[]string{"groups", "staging", "defaults"}, 8},
+		{[]string{"missing"}, 0},
+	}
+	for _, tt := range tests {
+		if got := keyLine(lines, tt.keys...); got != tt.want {
+			t.Errorf("%q: got line %d, want %d", tt.keys, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// takeoverSuffixes are hosting services whose names can be claimed by
+// someone else once the resource behind them is deleted.
+var takeoverSuffixes = []string{
+	".amazonaws.com",
+	".cloudfront.net",
+	".elasticbeanstalk.com",
+	".azurewebsites.net",
+	".cloudapp.net",
+	".trafficmanager.net",
+	".herokuapp.com",
+	".github.io",
+}
+
+// takeoverProne reports whether a target is on a hosting service where
+// dangling names can be taken over.
+func takeoverProne(target string) bool {
+	for _, s := range takeoverSuffixes {
+		if strings.HasSuffix(target, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// s3Missing reports whether an S3 hosted name answers that its bucket
+// doesn't exist. S3 names keep resolving after the bucket is deleted.
+func s3Missing(name string) bool {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + strings.TrimSuffix(name, "."))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	return err == nil && strings.Contains(string(body), "NoSuchBucket")
+}
+
+// danglingReason tells why a record looks dangling, or "" when it doesn't.
+func danglingReason(r record, apex string) string {
+	target := normalizeName(r.Value[0])
+	if inZone(target, apex) {
+		return ""
+	}
+
+	risk := ""
+	if takeoverProne(target) {
+		risk = ", subdomain takeover risk"
+	}
+
+	if _, err := net.LookupHost(target); err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			return fmt.Sprintf("target %s does not exist%s", target, risk)
+		}
+		return ""
+	}
+
+	if strings.Contains(target, ".s3") && strings.HasSuffix(target, ".amazonaws.com") && s3Missing(r.Name) {
+		return fmt.Sprintf("S3 bucket behind %s does not exist%s", target, risk)
+	}
+
+	return ""
+}
+
+// checkDangling takes CNAME records whose targets are gone out of the
+// zone's route53 records, listing them for manual action rather than
+// migrating a takeover risk. A records can't be checked against unallocated
+// addresses without the EC2 API.
+func checkDangling(z *zone) {
+	status.setPhase(z.apex(), "checking for dangling records")
+
+	kept := make([]record, 0, len(z.awsRecordSet))
+	for _, r := range z.awsRecordSet {
+		if r.Type == "CNAME" && len(r.Value) == 1 {
+			if reason := danglingReason(r, z.apex()); reason != "" {
+				z.manual = append(z.manual, fmt.Sprintf("%s CNAME: possibly dangling, %s, not migrated", r.Name, reason))
+				continue
+			}
+		}
+		kept = append(kept, r)
+	}
+
+	z.awsRecordSet = kept
+}
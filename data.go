@@ -0,0 +1,412 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// dataParsers convert the zone file presentation format of record types
+// cloudflare only accepts as structured data.
+var dataParsers = map[string]func(string) (map[string]interface{}, error){
+	"CERT":   certData,
+	"SMIMEA": smimeaData,
+	"LOC":    locData,
+	"NAPTR":  naptrData,
+	"DS":     dsData,
+}
+
+// validators check values of types cloudflare takes as content before they
+// are sent.
+var validators = map[string]func(string) error{
+	"AAAA": validAAAA,
+	"PTR":  validHostname,
+}
+
+// certTypes maps the CERT type mnemonics of RFC 4398 to their values.
+var certTypes = map[string]int{
+	"PKIX":    1,
+	"SPKI":    2,
+	"PGP":     3,
+	"IPKIX":   4,
+	"ISPKI":   5,
+	"IPGP":    6,
+	"ACPKIX":  7,
+	"IACPKIX": 8,
+	"URI":     253,
+	"OID":     254,
+}
+
+// dnssecAlgorithms maps the DNSSEC algorithm mnemonics to their values.
+var dnssecAlgorithms = map[string]int{
+	"RSAMD5":             1,
+	"DH":                 2,
+	"DSA":                3,
+	"RSASHA1":            5,
+	"DSA-NSEC3-SHA1":     6,
+	"RSASHA1-NSEC3-SHA1": 7,
+	"RSASHA256":          8,
+	"RSASHA512":          10,
+	"ECC-GOST":           12,
+	"ECDSAP256SHA256":    13,
+	"ECDSAP384SHA384":    14,
+	"ED25519":            15,
+	"ED448":              16,
+}
+
+// parseNumber parses a number in the range 0 to max, or a mnemonic.
+func parseNumber(s string, max int, mnemonics map[string]int) (int, error) {
+	if n, ok := mnemonics[strings.ToUpper(s)]; ok {
+		return n, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > max {
+		return 0, fmt.Errorf("invalid number '%s'", s)
+	}
+
+	return n, nil
+}
+
+// certData parses "type key_tag algorithm certificate".
+func certData(value string) (map[string]interface{}, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("Invalid CERT value '%s'", value)
+	}
+
+	typ, err := parseNumber(fields[0], 65535, certTypes)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CERT type in '%s'", value)
+	}
+
+	tag, err := parseNumber(fields[1], 65535, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CERT key tag in '%s'", value)
+	}
+
+	algorithm, err := parseNumber(fields[2], 255, dnssecAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid CERT algorithm in '%s'", value)
+	}
+
+	return map[string]interface{}{
+		"type":        typ,
+		"key_tag":     tag,
+		"algorithm":   algorithm,
+		"certificate": strings.Join(fields[3:], ""),
+	}, nil
+}
+
+// formatCERT renders CERT data in the presentation format, with the type
+// and algorithm as numbers.
+func formatCERT(data map[string]interface{}) string {
+	return fmt.Sprintf("%d %d %d %s", int(number(data["type"])), int(number(data["key_tag"])),
+		int(number(data["algorithm"])), data["certificate"])
+}
+
+// smimeaData parses "usage selector matching_type certificate".
+func smimeaData(value string) (map[string]interface{}, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("Invalid SMIMEA value '%s'", value)
+	}
+
+	numbers := make([]int, 3)
+	for i := range numbers {
+		n, err := parseNumber(fields[i], 255, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid SMIMEA value '%s'", value)
+		}
+		numbers[i] = n
+	}
+
+	return map[string]interface{}{
+		"usage":         numbers[0],
+		"selector":      numbers[1],
+		"matching_type": numbers[2],
+		"certificate":   strings.Join(fields[3:], ""),
+	}, nil
+}
+
+// formatSMIMEA renders SMIMEA data in the presentation format.
+func formatSMIMEA(data map[string]interface{}) string {
+	return fmt.Sprintf("%d %d %d %s", int(number(data["usage"])), int(number(data["selector"])),
+		int(number(data["matching_type"])), strings.ToUpper(fmt.Sprint(data["certificate"])))
+}
+
+// uriData parses `priority weight "target"`, returning the priority which
+// cloudflare keeps outside the data.
+func uriData(value string) (int, map[string]interface{}, error) {
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 3)
+	if len(fields) != 3 {
+		return 0, nil, fmt.Errorf("Invalid URI value '%s'", value)
+	}
+
+	priority, err := parseNumber(fields[0], 65535, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Invalid URI priority in '%s'", value)
+	}
+
+	weight, err := parseNumber(fields[1], 65535, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("Invalid URI weight in '%s'", value)
+	}
+
+	return priority, map[string]interface{}{
+		"weight": weight,
+		"target": parseTXT(fields[2]),
+	}, nil
+}
+
+// formatURI renders a URI priority and data in the presentation format.
+func formatURI(priority int, data map[string]interface{}) string {
+	return fmt.Sprintf(`%d %d "%s"`, priority, int(number(data["weight"])), data["target"])
+}
+
+// parseMeters parses a LOC distance such as "10m" or "-2.5".
+func parseMeters(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(s), "m"), 64)
+}
+
+// locData parses the RFC 1876 presentation format
+// "d1 [m1 [s1]] {N|S} d2 [m2 [s2]] {E|W} alt[m] [siz[m] [hp[m] [vp[m]]]]".
+func locData(value string) (map[string]interface{}, error) {
+	fields := strings.Fields(value)
+	invalid := fmt.Errorf("Invalid LOC value '%s'", value)
+
+	// coordinate reads degrees, optional minutes and seconds and the
+	// direction, returning the remaining fields
+	coordinate := func(fields []string, dirs string) ([]float64, string, []string, error) {
+		parts := make([]float64, 0, 3)
+		for len(fields) > 0 && len(parts) < 3 {
+			if strings.Contains(dirs, strings.ToUpper(fields[0])) {
+				break
+			}
+
+			n, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return nil, "", nil, invalid
+			}
+			parts = append(parts, n)
+			fields = fields[1:]
+		}
+
+		if len(parts) == 0 || len(fields) == 0 || !strings.Contains(dirs, strings.ToUpper(fields[0])) {
+			return nil, "", nil, invalid
+		}
+
+		for len(parts) < 3 {
+			parts = append(parts, 0)
+		}
+
+		return parts, strings.ToUpper(fields[0]), fields[1:], nil
+	}
+
+	lat, latDir, fields, err := coordinate(fields, "NS")
+	if err != nil {
+		return nil, err
+	}
+
+	long, longDir, fields, err := coordinate(fields, "EW")
+	if err != nil {
+		return nil, err
+	}
+
+	// altitude is required, size and precisions default per RFC 1876
+	meters := []float64{0, 1, 10000, 10}
+	if len(fields) == 0 || len(fields) > 4 {
+		return nil, invalid
+	}
+	for i, f := range fields {
+		m, err := parseMeters(f)
+		if err != nil {
+			return nil, invalid
+		}
+		meters[i] = m
+	}
+
+	return map[string]interface{}{
+		"lat_degrees":    int(lat[0]),
+		"lat_minutes":    int(lat[1]),
+		"lat_seconds":    lat[2],
+		"lat_direction":  latDir,
+		"long_degrees":   int(long[0]),
+		"long_minutes":   int(long[1]),
+		"long_seconds":   long[2],
+		"long_direction": longDir,
+		"altitude":       meters[0],
+		"size":           meters[1],
+		"precision_horz": meters[2],
+		"precision_vert": meters[3],
+	}, nil
+}
+
+// number returns a numeric value of decoded JSON data.
+func number(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	}
+
+	return 0
+}
+
+// formatLOC renders LOC data in the presentation format.
+func formatLOC(data map[string]interface{}) string {
+	return fmt.Sprintf("%d %d %.3f %v %d %d %.3f %v %.2fm %.2fm %.2fm %.2fm",
+		int(number(data["lat_degrees"])), int(number(data["lat_minutes"])), number(data["lat_seconds"]), data["lat_direction"],
+		int(number(data["long_degrees"])), int(number(data["long_minutes"])), number(data["long_seconds"]), data["long_direction"],
+		number(data["altitude"]), number(data["size"]), number(data["precision_horz"]), number(data["precision_vert"]))
+}
+
+// validAAAA checks an IPv6 address.
+func validAAAA(value string) error {
+	ip := net.ParseIP(value)
+	if ip == nil || !strings.Contains(value, ":") {
+		return fmt.Errorf("Invalid AAAA value '%s'", value)
+	}
+
+	return nil
+}
+
+// validHostname checks a host name, as PTR records point at.
+func validHostname(value string) error {
+	name := strings.TrimSuffix(value, ".")
+	if name == "" || len(name) > 253 {
+		return fmt.Errorf("Invalid host name '%s'", value)
+	}
+
+	for _, label := range strings.Split(toASCIIName(name), ".") {
+		if label == "" || len(label) > 63 || strings.Trim(label, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") != "" {
+			return fmt.Errorf("Invalid host name '%s'", value)
+		}
+	}
+
+	return nil
+}
+
+// splitQuoted splits a value into fields, keeping quoted character-strings
+// together and unquoting them.
+func splitQuoted(value string) []string {
+	fields := make([]string, 0)
+	value = strings.TrimSpace(value)
+	for value != "" {
+		end := strings.IndexAny(value, " \t")
+		if strings.HasPrefix(value, `"`) {
+			// find the closing quote, skipping escaped characters
+			end = -1
+			for i := 1; i < len(value); i++ {
+				if value[i] == '\\' {
+					i++
+					continue
+				}
+				if value[i] == '"' {
+					end = i + 1
+					break
+				}
+			}
+		}
+		if end < 0 {
+			end = len(value)
+		}
+
+		field := value[:end]
+		if strings.HasPrefix(field, `"`) {
+			field = parseTXT(field)
+		}
+		fields = append(fields, field)
+		value = strings.TrimSpace(value[end:])
+	}
+
+	return fields
+}
+
+// naptrData parses `order preference "flags" "service" "regexp" replacement`.
+func naptrData(value string) (map[string]interface{}, error) {
+	fields := splitQuoted(value)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("Invalid NAPTR value '%s'", value)
+	}
+
+	order, err := parseNumber(fields[0], 65535, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid NAPTR order in '%s'", value)
+	}
+
+	preference, err := parseNumber(fields[1], 65535, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid NAPTR preference in '%s'", value)
+	}
+
+	replacement := fields[5]
+	if replacement != "." {
+		replacement = strings.TrimSuffix(replacement, ".")
+	}
+
+	return map[string]interface{}{
+		"order":       order,
+		"preference":  preference,
+		"flags":       fields[2],
+		"service":     fields[3],
+		"regex":       fields[4],
+		"replacement": replacement,
+	}, nil
+}
+
+// formatNAPTR renders NAPTR data in the presentation format.
+func formatNAPTR(data map[string]interface{}) string {
+	replacement := fmt.Sprint(data["replacement"])
+	if replacement != "." {
+		replacement = strings.TrimSuffix(replacement, ".") + "."
+	}
+
+	return fmt.Sprintf("%d %d %s %s %s %s", int(number(data["order"])), int(number(data["preference"])),
+		quoteTXT(fmt.Sprint(data["flags"])), quoteTXT(fmt.Sprint(data["service"])), quoteTXT(fmt.Sprint(data["regex"])), replacement)
+}
+
+// dsData parses "key_tag algorithm digest_type digest", the digest may be
+// split into several fields.
+func dsData(value string) (map[string]interface{}, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("Invalid DS value '%s'", value)
+	}
+
+	tag, err := parseNumber(fields[0], 65535, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid DS key tag in '%s'", value)
+	}
+
+	algorithm, err := parseNumber(fields[1], 255, dnssecAlgorithms)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid DS algorithm in '%s'", value)
+	}
+
+	digestType, err := parseNumber(fields[2], 255, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid DS digest type in '%s'", value)
+	}
+
+	digest := strings.ToUpper(strings.Join(fields[3:], ""))
+	if _, err := hex.DecodeString(digest); err != nil {
+		return nil, fmt.Errorf("Invalid DS digest in '%s'", value)
+	}
+
+	return map[string]interface{}{
+		"key_tag":     tag,
+		"algorithm":   algorithm,
+		"digest_type": digestType,
+		"digest":      digest,
+	}, nil
+}
+
+// formatDS renders DS data in the presentation format.
+func formatDS(data map[string]interface{}) string {
+	return fmt.Sprintf("%d %d %d %s", int(number(data["key_tag"])), int(number(data["algorithm"])),
+		int(number(data["digest_type"])), strings.ToUpper(fmt.Sprint(data["digest"])))
+}
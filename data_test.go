@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDataNormalization(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value string
+		want  string
+	}{
+		{"CERT", "PGP 0 RSASHA256 AbCd EfGh", "3 0 8 AbCdEfGh"},
+		{"CERT", "1 12345 8 MIIB", "1 12345 8 MIIB"},
+		{"DS", "60485 ECDSAP256SHA256 2 d4b7d520e7bb5f0f 67674a0cceb1e3e0", "60485 13 2 D4B7D520E7BB5F0F67674A0CCEB1E3E0"},
+		{"LOC", "52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000m 10m", "52 22 23.000 N 4 53 32.000 E -2.00m 0.00m 10000.00m 10.00m"},
+		{"LOC", "52 N 4 E 10", "52 0 0.000 N 4 0 0.000 E 10.00m 1.00m 10000.00m 10.00m"},
+		{"NAPTR", `100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`, `100 10 "S" "SIP+D2U" "" _sip._udp.example.com.`},
+		{"NAPTR", `100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`, `100 10 "u" "E2U+sip" "!^.*$!sip:info@example.com!" .`},
+		{"SMIMEA", "3 1 1 abcdef", "3 1 1 ABCDEF"},
+	}
+
+	formats := map[string]func(map[string]interface{}) string{
+		"CERT":   formatCERT,
+		"DS":     formatDS,
+		"LOC":    formatLOC,
+		"NAPTR":  formatNAPTR,
+		"SMIMEA": formatSMIMEA,
+	}
+
+	for _, tt := range tests {
+		data, err := dataParsers[tt.typ](tt.value)
+		if err != nil {
+			t.Errorf("%s %q: %s", tt.typ, tt.value, err)
+			continue
+		}
+		if got := formats[tt.typ](data); got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.typ, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDataInvalid(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value string
+	}{
+		{"CERT", "PGP 0 8"},
+		{"CERT", "NOPE 0 8 AbCd"},
+		{"CERT", "3 70000 8 AbCd"},
+		{"DS", "60485 13 2 not-hex"},
+		{"DS", "60485 13 2"},
+		{"LOC", "52 22 23 X 4 53 32 E 0m"},
+		{"LOC", "52 N 4 E"},
+		{"NAPTR", `100 10 "S" "SIP+D2U" _sip._udp.example.com.`},
+		{"SMIMEA", "3 1 256 abcdef"},
+	}
+
+	for _, tt := range tests {
+		if _, err := dataParsers[tt.typ](tt.value); err == nil {
+			t.Errorf("%s %q: parsed, want an error", tt.typ, tt.value)
+		}
+	}
+}
+
+func TestURIData(t *testing.T) {
+	priority, data, err := uriData(`10 1 "https://example.com/path"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := formatURI(priority, data), `10 1 "https://example.com/path"`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if _, _, err := uriData(`10 "https://example.com/"`); err == nil {
+		t.Errorf("URI without a weight parsed")
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{`100 10 "S" "SIP+D2U" "" x.`, []string{"100", "10", "S", "SIP+D2U", "", "x."}},
+		{`1 "a b" "c\"d"`, []string{"1", "a b", `c"d`}},
+		{"  a \t b  ", []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		if got := splitQuoted(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value string
+		valid bool
+	}{
+		{"AAAA", "2001:db8::1", true},
+		{"AAAA", "192.0.2.1", false},
+		{"AAAA", "::ffff:192.0.2.1", true},
+		{"PTR", "host.example.com.", true},
+		{"PTR", "_service.example.com", true},
+		{"PTR", "bücher.example.com", true},
+		{"PTR", "bad..example.com", false},
+		{"PTR", "under score!.example.com", false},
+		{"PTR", ".", false},
+	}
+
+	for _, tt := range tests {
+		if err := validators[tt.typ](tt.value); (err == nil) != tt.valid {
+			t.Errorf("%s %q: got %v, want valid %v", tt.typ, tt.value, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// recordDiff is the difference between route53 and cloudflare for a single
+// name and type. A nil side means the record set only exists on the other.
+type recordDiff struct {
+	Name       string
+	Type       string
+	Route53    *record
+	Cloudflare *record
+
+	ignoreTTL bool
+
+	// canonical are the canonicalize rules the values are compared with
+	canonical canonicalRules
+
+	// lastChange tells who last changed the cloudflare records and when,
+	// from the audit log
+	lastChange string
+
+	// route53Change tells who last changed the route53 records and when,
+	// from CloudTrail
+	route53Change string
+}
+
+// proxyChanged reports whether a set present in both providers differs in
+// its proxy setting, the route53 side holding what the proxied rules ask
+// for.
+func (d recordDiff) proxyChanged() bool {
+	return d.Route53 != nil && d.Cloudflare != nil && d.Route53.Proxied != d.Cloudflare.Proxied
+}
+
+// ttlChanged reports whether a set present in both providers differs in
+// TTL, unless TTLs are ignored.
+func (d recordDiff) ttlChanged() bool {
+	return !d.ignoreTTL && d.Route53 != nil && d.Cloudflare != nil && d.Route53.TTL != d.Cloudflare.TTL
+}
+
+// recordKey identifies a record set independent of provider formatting.
+func recordKey(name, typ string) string {
+	return normalizeName(name) + " " + strings.ToUpper(typ)
+}
+
+// mixedTTL is the TTL of a grouped set whose records disagree on the TTL.
+const mixedTTL = -1
+
+// groupRecords merges records sharing a name and type into one set, as
+// cloudflare keeps one record per value.
+func groupRecords(records []record) (map[string]*record, []string) {
+	sets := make(map[string]*record)
+	keys := make([]string, 0)
+
+	for _, r := range records {
+		k := recordKey(r.Name, r.Type)
+		if s, ok := sets[k]; ok {
+			s.Value = append(s.Value, r.Value...)
+			if s.TTL != r.TTL {
+				s.TTL = mixedTTL
+			}
+			if r.Modified.After(s.Modified) {
+				s.Modified = r.Modified
+			}
+			continue
+		}
+
+		s := r
+		s.Name = strings.TrimSuffix(r.Name, ".")
+		s.Value = append([]string{}, r.Value...)
+		sets[k] = &s
+		keys = append(keys, k)
+	}
+
+	return sets, keys
+}
+
+// normalizeValue returns a value in a canonical form, so that presentation
+// differences between providers don't show as drift. The canonicalizers of
+// the type apply first, then the canonicalize rules of the config file.
+func normalizeValue(rules canonicalRules, typ, value string) string {
+	value = canonicalValue(typ, value)
+
+	for _, r := range rules {
+		if r.Type != "" && r.Type != typ {
+			continue
+		}
+		if r.Pattern != "" {
+			value = r.pattern.ReplaceAllString(value, r.Replace)
+		}
+		if r.Lowercase {
+			value = strings.ToLower(value)
+		}
+	}
+
+	return value
+}
+
+// canonicalValue returns a value with only the canonicalizers of the type
+// applied, not the canonicalize rules. Values of other types, or that the
+// canonicalizer can't parse, lose a trailing dot only.
+func canonicalValue(typ, value string) string {
+	if canonical, ok := canonicalizers[typ]; ok {
+		if v, ok := canonical(value); ok {
+			return v
+		}
+	}
+	return strings.TrimSuffix(value, ".")
+}
+
+// normalizeValues returns the distinct normalized values in sorted order.
+func normalizeValues(rules canonicalRules, typ string, values []string) []string {
+	// a record set is a set, a value given twice is served once
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		n := normalizeValue(rules, typ, v)
+		if !seen[n] {
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	sort.Strings(out)
+
+	return out
+}
+
+func equalValues(rules canonicalRules, typ string, a, b []string) bool {
+	a, b = normalizeValues(rules, typ, a), normalizeValues(rules, typ, b)
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// diffZone compares the record sets of both providers, returning the
+// differences and the number of record sets that match.
+func diffZone(z *zone, ignoreTTL bool) ([]recordDiff, int) {
+	r53, r53Keys := groupRecords(z.awsRecordSet)
+	cf, cfKeys := groupRecords(z.cfRecordSet)
+
+	diffs := make([]recordDiff, 0)
+	matching := 0
+
+	for _, k := range r53Keys {
+		a := r53[k]
+		c, ok := cf[k]
+		if !ok {
+			diffs = append(diffs, recordDiff{Name: a.Name, Type: a.Type, Route53: a, canonical: z.canonical})
+			continue
+		}
+
+		d := recordDiff{Name: a.Name, Type: a.Type, Route53: a, Cloudflare: c, ignoreTTL: ignoreTTL, canonical: z.canonical}
+		if d.ttlChanged() || d.proxyChanged() || !equalValues(z.canonical, a.Type, a.Value, c.Value) {
+			diffs = append(diffs, d)
+			continue
+		}
+
+		matching++
+	}
+
+	for _, k := range cfKeys {
+		if _, ok := r53[k]; !ok {
+			c := cf[k]
+			diffs = append(diffs, recordDiff{Name: c.Name, Type: c.Type, Cloudflare: c, canonical: z.canonical})
+		}
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		return recordKey(diffs[i].Name, diffs[i].Type) < recordKey(diffs[j].Name, diffs[j].Type)
+	})
+
+	return diffs, matching
+}
+
+// splitValues returns the normalized values only found in a and those only
+// found in b.
+func splitValues(rules canonicalRules, typ string, a, b []string) ([]string, []string) {
+	onlyA := make([]string, 0)
+	onlyB := make([]string, 0)
+
+	na, nb := normalizeValues(rules, typ, a), normalizeValues(rules, typ, b)
+	inA := make(map[string]bool)
+	inB := make(map[string]bool)
+	for _, v := range na {
+		inA[v] = true
+	}
+	for _, v := range nb {
+		inB[v] = true
+	}
+
+	for _, v := range na {
+		if !inB[v] {
+			onlyA = append(onlyA, v)
+		}
+	}
+	for _, v := range nb {
+		if !inA[v] {
+			onlyB = append(onlyB, v)
+		}
+	}
+
+	return onlyA, onlyB
+}
+
+func formatTTL(ttl int) string {
+	if ttl == mixedTTL {
+		return "mixed"
+	}
+	return fmt.Sprintf("%d", ttl)
+}
+
+func formatRecord(rules canonicalRules, r *record) string {
+	s := fmt.Sprintf("%s %s", formatTTL(r.TTL), strings.Join(normalizeValues(rules, r.Type, r.Value), ", "))
+	if r.Proxied {
+		s += " (proxied)"
+	}
+	return s
+}
+
+// formatProxied renders a proxy setting.
+func formatProxied(proxied bool) string {
+	if proxied {
+		return "proxied"
+	}
+	return "dns only"
+}
+
+// printDiff writes the differences of a zone in a human readable form.
+func printDiff(diffs []recordDiff, matching int) {
+	sections := []struct {
+		title string
+		match func(d recordDiff) bool
+	}{
+		{"Missing in cloudflare:", func(d recordDiff) bool { return d.Cloudflare == nil }},
+		{"Only in cloudflare:", func(d recordDiff) bool { return d.Route53 == nil }},
+		{"Different:", func(d recordDiff) bool { return d.Route53 != nil && d.Cloudflare != nil }},
+	}
+
+	for _, s := range sections {
+		header := false
+		for _, d := range diffs {
+			if !s.match(d) {
+				continue
+			}
+
+			if !header {
+				fmt.Println(s.title)
+				header = true
+			}
+
+			switch {
+			case d.Cloudflare == nil:
+				fmt.Printf("  %s %s %s\n", d.Name, d.Type, formatRecord(d.canonical, d.Route53))
+			case d.Route53 == nil:
+				fmt.Printf("  %s %s %s\n", d.Name, d.Type, formatRecord(d.canonical, d.Cloudflare))
+			default:
+				fmt.Printf("  %s %s\n", d.Name, d.Type)
+
+				// only show the values that differ, multi-value sets can
+				// be large
+				missing, extra := splitValues(d.canonical, d.Type, d.Route53.Value, d.Cloudflare.Value)
+				for _, v := range missing {
+					fmt.Printf("    missing in cloudflare: %s\n", v)
+				}
+				for _, v := range extra {
+					fmt.Printf("    only in cloudflare:    %s\n", v)
+				}
+				if d.ttlChanged() {
+					fmt.Printf("    ttl: route53 %s, cloudflare %s\n", formatTTL(d.Route53.TTL), formatTTL(d.Cloudflare.TTL))
+				}
+				if d.proxyChanged() {
+					fmt.Printf("    proxy: wanted %s, cloudflare %s\n", formatProxied(d.Route53.Proxied), formatProxied(d.Cloudflare.Proxied))
+				}
+			}
+
+			if d.lastChange != "" {
+				fmt.Printf("    last changed in cloudflare by %s\n", d.lastChange)
+			}
+			if d.route53Change != "" {
+				fmt.Printf("    last changed in route53 by %s\n", d.route53Change)
+			}
+		}
+	}
+
+	for _, d := range diffs {
+		if d.Type == "LOC" && d.Route53 == nil {
+			fmt.Println("NOTE: route53 does not support LOC records, they can only be kept in cloudflare")
+			break
+		}
+	}
+
+	fmt.Printf("%d matching record sets, %d differences\n", matching, len(diffs))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+var valueSetTests = []struct {
+	typ   string
+	a, b  []string
+	equal bool
+	onlyA []string
+	onlyB []string
+}{
+	{"A", []string{"192.0.2.1", "192.0.2.2"}, []string{"192.0.2.2", "192.0.2.1"}, true, []string{}, []string{}},
+	{"A", []string{"192.0.2.1", "192.0.2.1"}, []string{"192.0.2.1"}, true, []string{}, []string{}},
+	{"A", []string{"192.0.2.1"}, []string{"192.0.2.1", "192.0.2.1", "192.0.2.2"}, false, []string{}, []string{"192.0.2.2"}},
+	{"CNAME", []string{"web.example.net."}, []string{"Web.Example.Net"}, true, []string{}, []string{}},
+	{"MX", []string{"10 mx1.example.com.", "10 mx1.example.com"}, []string{"10 mx1.example.com"}, true, []string{}, []string{}},
+	{"TXT", []string{`"a" "b"`}, []string{`"ab"`}, true, []string{}, []string{}},
+}
+
+func TestValueSets(t *testing.T) {
+	for _, tt := range valueSetTests {
+		if got := equalValues(nil, tt.typ, tt.a, tt.b); got != tt.equal {
+			t.Errorf("equalValues %s %q %q: got %v, want %v", tt.typ, tt.a, tt.b, got, tt.equal)
+		}
+
+		onlyA, onlyB := splitValues(nil, tt.typ, tt.a, tt.b)
+		if !reflect.DeepEqual(onlyA, tt.onlyA) || !reflect.DeepEqual(onlyB, tt.onlyB) {
+			t.Errorf("splitValues %s %q %q: got %q %q, want %q %q", tt.typ, tt.a, tt.b, onlyA, onlyB, tt.onlyA, tt.onlyB)
+		}
+
+		// the values differ exactly when some are only on one side
+		if split := len(onlyA)+len(onlyB) > 0; split == tt.equal {
+			t.Errorf("%s %q %q: equalValues and splitValues disagree", tt.typ, tt.a, tt.b)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	dnssecCmd.Flags().Bool("enable", false, "Enable DNSSEC on the Cloudflare zones where it is off")
+	viper.BindPFlag("dnssec-enable", dnssecCmd.Flags().Lookup("enable"))
+
+	rootCmd.AddCommand(dnssecCmd)
+}
+
+var dnssecCmd = &cobra.Command{
+	Use:   "dnssec",
+	Short: "Show the Cloudflare DNSSEC status of the zones and the DS record for the registrar",
+	Long: `Shows whether DNSSEC is enabled on the Cloudflare zones and, once it is,
+the DS record to publish at the registrar. With --enable DNSSEC is turned on
+where it is off.
+
+The Route53 signing status is shown too. If a zone is signed at Route53 the
+registrar holds a DS record for the Route53 key, which must be replaced by
+the Cloudflare one when the name servers change, or validating resolvers
+will fail the zone.`,
+	Run: doDNSSEC,
+}
+
+// dnssecStatus is the DNSSEC state of a cloudflare zone.
+type dnssecStatus struct {
+	Status     string `json:"status"`
+	DS         string `json:"ds"`
+	KeyTag     int    `json:"key_tag"`
+	Algorithm  string `json:"algorithm"`
+	DigestType string `json:"digest_type"`
+	Digest     string `json:"digest"`
+}
+
+// zoneDNSSEC reads the DNSSEC state of a cloudflare zone, or changes it to
+// status when set.
+func zoneDNSSEC(cfg *config, zoneID, status string) (dnssecStatus, error) {
+	var s dnssecStatus
+
+	method, data := "GET", interface{}(nil)
+	if status != "" {
+		method, data = "PATCH", map[string]string{"status": status}
+	}
+
+	raw, err := cfg.api.Raw(method, "/zones/"+zoneID+"/dnssec", data)
+	if err != nil {
+		return s, fmt.Errorf("Unable to read DNSSEC status: %s", err)
+	}
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return s, fmt.Errorf("Unable to read DNSSEC status: %s", err)
+	}
+
+	return s, nil
+}
+
+// route53API is the endpoint and version of the route53 API, which the
+// SDK vendored predates DNSSEC signing in.
+const route53API = "https://route53.amazonaws.com/2013-04-01"
+
+// route53Signing is the DNSSEC state of a route53 hosted zone.
+type route53Signing struct {
+	ServeSignature string `xml:"Status>ServeSignature"`
+	KeySigningKeys []struct {
+		Name     string `xml:"Name"`
+		Status   string `xml:"Status"`
+		DSRecord string `xml:"DSRecord"`
+	} `xml:"KeySigningKeys>member"`
+}
+
+// hostedZoneDNSSEC reads the DNSSEC state of a route53 hosted zone.
+func hostedZoneDNSSEC(cfg *config, hostedZoneID string) (route53Signing, error) {
+	var s route53Signing
+
+	u := route53API + "/hostedzone/" + strings.TrimPrefix(hostedZoneID, "/hostedzone/") + "/dnssec"
+	if err := awsRESTCall(cfg.session, "route53", "us-east-1", u, &s); err != nil {
+		return s, fmt.Errorf("Unable to read the DNSSEC status of hosted zone %s: %s", hostedZoneID, err)
+	}
+
+	return s, nil
+}
+
+func doDNSSEC(cmd *cobra.Command, args []string) {
+	enable := viper.GetBool("dnssec-enable")
+
+	runZones(func(cfg *config, z *zone) error {
+		zoneID, err := cfg.api.ZoneIDByName(z.apex())
+		if err != nil {
+			return fmt.Errorf("No cloudflare zone %s: %s", z.apex(), err)
+		}
+
+		s, err := zoneDNSSEC(cfg, zoneID, "")
+		if err != nil {
+			return err
+		}
+
+		if s.Status == "disabled" && enable {
+			if s, err = zoneDNSSEC(cfg, zoneID, "active"); err != nil {
+				return err
+			}
+		}
+
+		signing, err := hostedZoneDNSSEC(cfg, z.hostedZoneID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Zone: %s\n", z.apex())
+		fmt.Printf("  route53 DNSSEC: %s\n", strings.ToLower(signing.ServeSignature))
+		for _, k := range signing.KeySigningKeys {
+			if k.Status == "ACTIVE" {
+				fmt.Printf("    key %s, DS record %s\n", k.Name, k.DSRecord)
+			}
+		}
+		fmt.Printf("  cloudflare DNSSEC: %s\n", s.Status)
+
+		switch s.Status {
+		case "disabled":
+			fmt.Println("  run with --enable to sign the zone at cloudflare")
+		case "pending", "active":
+			fmt.Println("  DS record for the registrar:")
+			fmt.Printf("    %s\n", s.DS)
+			fmt.Printf("    key tag %d, algorithm %s, digest type %s, digest %s\n", s.KeyTag, s.Algorithm, s.DigestType, s.Digest)
+			if signing.ServeSignature == "SIGNING" {
+				fmt.Println("  the zone is signed at route53, replace its DS record at the registrar with this one")
+			}
+		}
+
+		return nil
+	})
+}
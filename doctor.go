@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that the selected zones are ready to migrate",
+	Long: `Runs the preflight checks of a migration and prints a checklist: the
+credentials and their permissions, as whoami checks them, then for each
+selected zone that its Route53 records can be read, that its Cloudflare
+zone exists with editable DNS records, the record counts, that every
+record can be translated, and that the planned writes fit Cloudflare's
+API rate limit.`,
+	Args: cobra.NoArgs,
+	Run:  doDoctor,
+}
+
+// cloudflareRateLimit is the number of API requests cloudflare allows
+// per five minutes.
+const cloudflareRateLimit = 1200
+
+// doctorReport prints the checklist and counts the failures.
+type doctorReport struct {
+	failed int
+}
+
+func (r *doctorReport) pass(name, detail string) {
+	fmt.Printf("[PASS] %s: %s\n", name, detail)
+}
+
+func (r *doctorReport) warn(name, detail string) {
+	fmt.Printf("[WARN] %s: %s\n", name, detail)
+}
+
+func (r *doctorReport) fail(name string, err error) {
+	fmt.Printf("[FAIL] %s: %s\n", name, err)
+	r.failed++
+}
+
+// check reports the result of a check returning its detail.
+func (r *doctorReport) check(name string, detail string, err error) bool {
+	if err != nil {
+		r.fail(name, err)
+		return false
+	}
+
+	r.pass(name, detail)
+	return true
+}
+
+// diagnoseZone checks a zone, returning the cloudflare writes migrating
+// it takes.
+func diagnoseZone(cfg *config, z *zone, r *doctorReport) int {
+	fmt.Printf("Zone: %s\n", z.apex())
+
+	if err := fetchRoute53(cfg, z); err != nil {
+		r.fail("Route53 records readable", err)
+		return 0
+	}
+	r.pass("Route53 records readable", fmt.Sprintf("%d records", len(z.awsRecordSet)))
+
+	if err := fetchCloudflare(cfg, z, z.apex()); err != nil {
+		r.fail("Cloudflare zone present", err)
+		return 0
+	}
+	r.pass("Cloudflare zone present", fmt.Sprintf("%d records", len(z.cfRecordSet)))
+
+	details, err := cfg.api.ZoneDetails(z.zoneID)
+	switch {
+	case err != nil:
+		r.fail("Cloudflare DNS edit", err)
+	case len(details.Permissions) == 0:
+		r.warn("Cloudflare DNS edit", "not listed for API tokens, see whoami")
+	case !strings.Contains(strings.Join(details.Permissions, " "), "#dns_records:edit"):
+		r.fail("Cloudflare DNS edit", fmt.Errorf("the credentials can only read the DNS records of %s", z.apex()))
+	default:
+		r.pass("Cloudflare DNS edit", "allowed")
+	}
+
+	plan, skipped := planZone(z)
+	untranslatable := append(append([]string{}, z.manual...), skipped...)
+	if len(untranslatable) > 0 {
+		r.fail("Records translatable", fmt.Errorf("%d need manual action:\n       %s", len(untranslatable), strings.Join(untranslatable, "\n       ")))
+	} else {
+		r.pass("Records translatable", "all")
+	}
+
+	validateZone(z)
+	if len(z.problems) > 0 {
+		r.warn("Records valid", fmt.Sprintf("%d problems:\n       %s", len(z.problems), strings.Join(z.problems, "\n       ")))
+	} else {
+		r.pass("Records valid", "no problems found")
+	}
+
+	if errs := checkPlan(z, plan); len(errs) > 0 {
+		r.fail("Plan valid", fmt.Errorf("%d errors, migrate would refuse it:\n       %s", len(errs), strings.Join(errs, "\n       ")))
+	} else {
+		r.pass("Plan valid", fmt.Sprintf("%d writes", len(plan)))
+	}
+
+	return len(plan) + len(z.balanced)
+}
+
+func doDoctor(cmd *cobra.Command, args []string) {
+	r := &doctorReport{}
+
+	cfg, err := assembleConfig()
+	if !r.check("Configuration", "complete", err) {
+		checkErr(fmt.Errorf("%d checks failed", r.failed))
+	}
+
+	for _, c := range credentialChecks {
+		detail, err := c.Run(cfg)
+		r.check(c.Name, detail, err)
+	}
+
+	zones, err := findZones(cfg)
+	if !r.check("Zones selected", fmt.Sprintf("%d", len(zones)), err) {
+		checkErr(fmt.Errorf("%d checks failed", r.failed))
+	}
+
+	writes := 0
+	for _, z := range zones {
+		writes += diagnoseZone(cfg, z, r)
+	}
+
+	fmt.Println("Run:")
+	headroom := fmt.Sprintf("%d cloudflare writes planned, the API allows %d requests per 5 minutes", writes, cloudflareRateLimit)
+	if writes > cloudflareRateLimit {
+		r.warn("Rate limit headroom", headroom+", migrate will be slowed by rate limiting, --max-duration spreads it over runs")
+	} else {
+		r.pass("Rate limit headroom", headroom)
+	}
+
+	if r.failed > 0 {
+		checkErr(fmt.Errorf("%d checks failed", r.failed))
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/smtp"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringSlice("email-to", nil, "Email the compare report of all zones as HTML to this address (repeatable)")
+	viper.BindPFlag("email-to", rootCmd.PersistentFlags().Lookup("email-to"))
+
+	rootCmd.PersistentFlags().String("email-from", "", "Sender address of the compare report email")
+	viper.BindPFlag("email-from", rootCmd.PersistentFlags().Lookup("email-from"))
+
+	rootCmd.PersistentFlags().String("smtp-server", "", "SMTP server the report is sent through as host:port, such as an SES SMTP endpoint")
+	viper.BindPFlag("smtp-server", rootCmd.PersistentFlags().Lookup("smtp-server"))
+
+	rootCmd.PersistentFlags().String("smtp-user", "", "SMTP user name, no authentication when empty")
+	viper.BindPFlag("smtp-user", rootCmd.PersistentFlags().Lookup("smtp-user"))
+
+	rootCmd.PersistentFlags().String("smtp-password-file", "", "File containing the SMTP password, which can also be set as smtp-password in the config file")
+	viper.BindPFlag("smtp-password-file", rootCmd.PersistentFlags().Lookup("smtp-password-file"))
+
+	configSetting("smtp-password", true)
+	configSetting("email-recipients", false)
+}
+
+// emailRecipients maps each recipient of the report to the zones they get.
+// --email-to recipients get all zones, the email-recipients section of the
+// config file maps domain patterns to the recipients of matching zones:
+//
+//	email-recipients:
+//	  "*.example.com": [dns-team@example.com]
+//	  shop.example.net: [shop@example.net, ops@example.net]
+func emailRecipients(zones []*zone) (map[string][]*zone, error) {
+	recipients := make(map[string][]*zone)
+	for _, to := range viper.GetStringSlice("email-to") {
+		recipients[to] = zones
+	}
+
+	for pattern, addresses := range viper.GetStringMapStringSlice("email-recipients") {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid domain pattern '%s' in email-recipients: %s", pattern, err)
+		}
+
+		for _, z := range zones {
+			if ok, _ := path.Match(pattern, normalizeName(z.apex())); !ok {
+				continue
+			}
+			for _, to := range addresses {
+				if !containsZone(recipients[to], z) {
+					recipients[to] = append(recipients[to], z)
+				}
+			}
+		}
+	}
+
+	return recipients, nil
+}
+
+func containsZone(zones []*zone, z *zone) bool {
+	for _, c := range zones {
+		if c == z {
+			return true
+		}
+	}
+	return false
+}
+
+// renderEmail renders the compare results of the zones as an HTML email
+// body, a summary table followed by the differences of each zone.
+func renderEmail(zones []*zone) (string, string) {
+	differences := 0
+	for _, z := range zones {
+		differences += len(z.diffs)
+	}
+	title := "cfmigrate compare"
+	if group := viper.GetString("group"); group != "" {
+		title += " of group " + group
+	}
+	subject := fmt.Sprintf("%s: %d zones, %d differences", title, len(zones), differences)
+
+	b := &bytes.Buffer{}
+	fmt.Fprintf(b, "<html><body>\n<h3>%s</h3>\n", html.EscapeString(subject))
+	fmt.Fprintf(b, "<table border=\"1\" cellpadding=\"4\" cellspacing=\"0\">\n<tr><th>Zone</th><th>Matching</th><th>Differences</th><th>Needs manual action</th></tr>\n")
+	for _, z := range zones {
+		fmt.Fprintf(b, "<tr><td>%s</td><td>%d</td><td>%d</td><td>%d</td></tr>\n", html.EscapeString(z.apex()), z.matching, len(z.diffs), len(z.manual))
+	}
+	fmt.Fprintf(b, "</table>\n")
+
+	for _, z := range zones {
+		lines := diffLines(z.diffs)
+		if len(lines) == 0 && len(z.manual) == 0 && len(z.geo) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(b, "<h4>%s</h4>\n", html.EscapeString(z.apex()))
+		if !z.stale.IsZero() {
+			fmt.Fprintf(b, "<p>Cloudflare records are from the cache of %s.</p>\n", z.stale.Format(time.RFC3339))
+		}
+		if len(lines) > 0 {
+			fmt.Fprintf(b, "<pre>%s</pre>\n", html.EscapeString(strings.Join(lines, "\n")))
+		}
+		if len(z.manual) > 0 {
+			fmt.Fprintf(b, "<p>Needs manual action:</p>\n<ul>\n")
+			for _, m := range z.manual {
+				fmt.Fprintf(b, "<li>%s</li>\n", html.EscapeString(m))
+			}
+			fmt.Fprintf(b, "</ul>\n")
+		}
+		renderGeoTables(b, z)
+	}
+
+	fmt.Fprintf(b, "</body></html>\n")
+
+	return subject, b.String()
+}
+
+// sendReports emails the compare report to the configured recipients, each
+// getting the zones meant for them.
+func sendReports(zones []*zone) error {
+	recipients, err := emailRecipients(zones)
+	if err != nil || len(recipients) == 0 {
+		return err
+	}
+
+	server := viper.GetString("smtp-server")
+	if server == "" {
+		return errors.New("No SMTP server supplied for the report email")
+	}
+
+	from := viper.GetString("email-from")
+	if from == "" {
+		return errors.New("No sender address supplied for the report email")
+	}
+
+	var auth smtp.Auth
+	if user := viper.GetString("smtp-user"); user != "" {
+		password, err := secretValue("smtp-password")
+		if err != nil {
+			return err
+		}
+
+		host, _, err := net.SplitHostPort(server)
+		if err != nil {
+			return fmt.Errorf("Invalid SMTP server '%s': %s", server, err)
+		}
+		auth = smtp.PlainAuth("", user, password, host)
+	}
+
+	addresses := make([]string, 0, len(recipients))
+	for to := range recipients {
+		addresses = append(addresses, to)
+	}
+	sort.Strings(addresses)
+
+	for _, to := range addresses {
+		subject, body := renderEmail(recipients[to])
+
+		msg := &bytes.Buffer{}
+		fmt.Fprintf(msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n", from, to, subject, time.Now().Format(time.RFC1123Z))
+		fmt.Fprintf(msg, "MIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s", body)
+
+		if err := smtp.SendMail(server, auth, from, []string{to}, msg.Bytes()); err != nil {
+			return fmt.Errorf("Unable to email the report to %s: %s", to, err)
+		}
+	}
+
+	return nil
+}
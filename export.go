@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	exportCmd.Flags().Bool("all", false, "Export every hosted zone and every Cloudflare zone of the accounts instead of the selected zones")
+	viper.BindPFlag("all", exportCmd.Flags().Lookup("all"))
+
+	exportCmd.Flags().String("format", "bind", "Format of the zone files, only bind is supported")
+	viper.BindPFlag("format", exportCmd.Flags().Lookup("format"))
+
+	exportCmd.Flags().String("dir", "", "Directory to write the zone files and manifest.json to")
+	viper.BindPFlag("dir", exportCmd.Flags().Lookup("dir"))
+
+	rootCmd.AddCommand(exportCmd)
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write the zones of both providers as zone files",
+	Long: `Writes a BIND zone file per zone and provider into --dir, route53/ and
+cloudflare/, as the providers hold them: Route53 alias and routing policy
+record sets are kept as commented records, private hosted zones are marked
+with their VPCs, and proxied Cloudflare records are marked. manifest.json lists each file with its zone, record count and
+SHA-256 checksum, for backups.`,
+	Args: cobra.NoArgs,
+	Run:  doExport,
+}
+
+// exportEntry is a zone file of the manifest.
+type exportEntry struct {
+	Provider string `json:"provider"`
+	Zone     string `json:"zone"`
+	ID       string `json:"id"`
+	File     string `json:"file"`
+	Records  int    `json:"records"`
+	SHA256   string `json:"sha256"`
+
+	// Private hosted zones are restored into a private zone of the same
+	// VPCs, given as region/vpc-id
+	Private bool     `json:"private,omitempty"`
+	VPCs    []string `json:"vpcs,omitempty"`
+}
+
+// exportManifest indexes the zone files of an export.
+type exportManifest struct {
+	Exported time.Time     `json:"exported"`
+	Zones    []exportEntry `json:"zones"`
+}
+
+// zoneFileHeader starts a zone file.
+func zoneFileHeader(b *bytes.Buffer, provider, name, id string) {
+	fmt.Fprintf(b, "; %s zone %s (%s) exported by cfmigrate on %s\n", provider, name, id, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(b, "$ORIGIN %s.\n", normalizeName(name))
+}
+
+// hostedZoneVPCs tells whether a hosted zone is private, with the VPCs it
+// serves as region/vpc-id.
+func hostedZoneVPCs(cfg *config, id string) (bool, []string, error) {
+	out, err := cfg.r53.GetHostedZone(&route53.GetHostedZoneInput{Id: aws.String(id)})
+	if err != nil {
+		return false, nil, fmt.Errorf("Unable to read hosted zone %s: %s", id, err)
+	}
+
+	vpcs := make([]string, 0, len(out.VPCs))
+	for _, v := range out.VPCs {
+		vpcs = append(vpcs, aws.StringValue(v.VPCRegion)+"/"+aws.StringValue(v.VPCId))
+	}
+
+	return aws.BoolValue(out.HostedZone.Config.PrivateZone), vpcs, nil
+}
+
+// route53ZoneFile renders the record sets of a hosted zone. Aliases and
+// routing policies have no zone file form, they are written commented out.
+// Names are unescaped, as cloudflare shows them.
+func route53ZoneFile(cfg *config, name, id string, vpcs []string) ([]byte, int, error) {
+	b := &bytes.Buffer{}
+	zoneFileHeader(b, "route53", name, id)
+	if len(vpcs) > 0 {
+		fmt.Fprintf(b, "; private zone of VPCs %s\n", strings.Join(vpcs, ", "))
+	}
+
+	count := 0
+	err := cfg.r53.ListResourceRecordSetsPages(&route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(id),
+	}, func(page *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+		count += len(page.ResourceRecordSets)
+		writeRoute53Records(b, page.ResourceRecordSets)
+		return true
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("Unable to read hosted zone %s: %s", id, err)
+	}
+
+	return b.Bytes(), count, nil
+}
+
+// writeRoute53Records writes route53 record sets as zone file lines.
+func writeRoute53Records(b *bytes.Buffer, sets []*route53.ResourceRecordSet) {
+	for _, r := range sets {
+		name := unescapeName(*r.Name)
+
+		prefix, set := "", ""
+		if r.SetIdentifier != nil {
+			prefix = "; "
+			set = fmt.Sprintf("\t; set %s, %s", *r.SetIdentifier, routingPolicy(r))
+		}
+
+		if r.AliasTarget != nil {
+			fmt.Fprintf(b, "; %s ALIAS %s %s (hosted zone %s)%s\n", name, *r.Type, *r.AliasTarget.DNSName, aws.StringValue(r.AliasTarget.HostedZoneId), set)
+			continue
+		}
+
+		for _, rr := range r.ResourceRecords {
+			fmt.Fprintf(b, "%s%s\t%d\tIN\t%s\t%s%s\n", prefix, name, aws.Int64Value(r.TTL), *r.Type, zoneFileValue(*r.Type, *rr.Value), set)
+		}
+	}
+}
+
+// zoneFileValue converts a value in route53 presentation format to the
+// form of a BIND zone file: the names it holds are fully qualified, as a
+// loader reads them relative to $ORIGIN otherwise, and TXT escapes are
+// decimal as RFC 1035 has them where route53 writes octal.
+func zoneFileValue(typ, value string) string {
+	field := -1
+	switch typ {
+	case "TXT", "SPF":
+		return quoteZoneTXT(parseTXT(value))
+	case "CNAME", "NS", "PTR":
+		field = 0
+	case "MX":
+		field = 1
+	case "SRV":
+		field = 3
+	}
+
+	fields := strings.Fields(value)
+	if field < 0 || field >= len(fields) || strings.HasSuffix(fields[field], ".") {
+		return value
+	}
+
+	fields[field] += "."
+	return strings.Join(fields, " ")
+}
+
+// cloudflareZoneFile renders the records of a cloudflare zone.
+func cloudflareZoneFile(cfg *config, name, id string) ([]byte, int, error) {
+	records, err := cfg.api.DNSRecords(id, cloudflare.DNSRecord{})
+	if err != nil {
+		return nil, 0, fmt.Errorf("Unable to read cloudflare zone %s: %s", name, err)
+	}
+
+	b := &bytes.Buffer{}
+	zoneFileHeader(b, "cloudflare", name, id)
+	writeCloudflareRecords(b, records)
+
+	return b.Bytes(), len(records), nil
+}
+
+// writeCloudflareRecords writes cloudflare records as zone file lines. The
+// automatic TTL is written as the TTL cloudflare serves, noted in the
+// comment so restore brings it back.
+func writeCloudflareRecords(b *bytes.Buffer, records []cloudflare.DNSRecord) {
+	for _, cr := range records {
+		r := fromCloudflare(cr)
+
+		notes := make([]string, 0, 2)
+		if r.Proxied {
+			notes = append(notes, "proxied")
+		}
+		ttl := r.TTL
+		if ttl == autoTTL {
+			ttl = autoTTLSeconds
+			notes = append(notes, "auto TTL")
+		}
+
+		note := ""
+		if len(notes) > 0 {
+			note = "\t; " + strings.Join(notes, ", ")
+		}
+		fmt.Fprintf(b, "%s.\t%d\tIN\t%s\t%s%s\n", normalizeName(r.Name), ttl, r.Type, zoneFileValue(r.Type, r.Value[0]), note)
+	}
+}
+
+// writeZoneFile writes a zone file under dir, returning its manifest entry.
+func writeZoneFile(dir, provider, name, id string, data []byte, records int) (exportEntry, error) {
+	file := filepath.Join(provider, normalizeName(name)+".zone")
+	if provider == "route53" {
+		// private and public zones can share a name
+		file = filepath.Join(provider, normalizeName(name)+"."+strings.TrimPrefix(id, "/hostedzone/")+".zone")
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, provider), 0700); err != nil {
+		return exportEntry{}, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, file), data, 0600); err != nil {
+		return exportEntry{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	return exportEntry{
+		Provider: provider,
+		Zone:     normalizeName(name),
+		ID:       id,
+		File:     file,
+		Records:  records,
+		SHA256:   hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+// exportTargets lists the zones to export, every zone of both accounts or
+// the selected zones and their cloudflare zones.
+func exportTargets(cfg *config) (map[string]string, map[string]string, error) {
+	r53 := make(map[string]string)
+	cf := make(map[string]string)
+
+	if !viper.GetBool("all") {
+		zones, err := findZones(cfg)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, z := range zones {
+			r53[z.hostedZoneID] = z.name
+			id, err := cfg.api.ZoneIDByName(z.apex())
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "WARNING: no cloudflare zone %s to export: %s\n", z.apex(), err)
+				continue
+			}
+			cf[id] = z.apex()
+		}
+
+		return r53, cf, nil
+	}
+
+	err := cfg.r53.ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(page *route53.ListHostedZonesOutput, lastPage bool) bool {
+		for _, hz := range page.HostedZones {
+			r53[*hz.Id] = strings.TrimSuffix(*hz.Name, ".")
+		}
+		return true
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	zones, err := cfg.api.ListZones()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, z := range zones {
+		cf[z.ID] = z.Name
+	}
+
+	return r53, cf, nil
+}
+
+func doExport(cmd *cobra.Command, args []string) {
+	dir := viper.GetString("dir")
+	if dir == "" {
+		checkErr(errors.New("No export directory supplied"))
+	}
+	if f := viper.GetString("format"); f != "bind" {
+		checkErr(fmt.Errorf("Unknown export format '%s'", f))
+	}
+
+	// every zone is exported, no domain is needed to select them
+	if viper.GetBool("all") && viper.GetString("domain") == "" && viper.GetString("group") == "" {
+		viper.Set("domain", "*")
+	}
+
+	cfg, err := assembleConfig()
+	checkErr(err)
+
+	r53, cf, err := exportTargets(cfg)
+	checkErr(err)
+
+	manifest := exportManifest{Exported: time.Now().UTC(), Zones: make([]exportEntry, 0, len(r53)+len(cf))}
+	for id, name := range r53 {
+		private, vpcs, err := hostedZoneVPCs(cfg, id)
+		checkErr(err)
+		if !private {
+			vpcs = nil
+		}
+
+		data, count, err := route53ZoneFile(cfg, name, id, vpcs)
+		checkErr(err)
+
+		entry, err := writeZoneFile(dir, "route53", name, id, data, count)
+		checkErr(err)
+		entry.Private, entry.VPCs = private, vpcs
+		manifest.Zones = append(manifest.Zones, entry)
+	}
+
+	for id, name := range cf {
+		data, count, err := cloudflareZoneFile(cfg, name, id)
+		checkErr(err)
+
+		entry, err := writeZoneFile(dir, "cloudflare", name, id, data, count)
+		checkErr(err)
+		manifest.Zones = append(manifest.Zones, entry)
+	}
+
+	sort.Slice(manifest.Zones, func(i, j int) bool { return manifest.Zones[i].File < manifest.Zones[j].File })
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	checkErr(err)
+	checkErr(ioutil.WriteFile(filepath.Join(dir, "manifest.json"), b, 0600))
+
+	fmt.Printf("Exported %d route53 and %d cloudflare zones to %s\n", len(r53), len(cf), dir)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var zoneFileValueTests = []struct {
+	typ   string
+	value string
+	want  string
+}{
+	{"CNAME", "web.example.net", "web.example.net."},
+	{"CNAME", "web.example.net.", "web.example.net."},
+	{"NS", "ns1.example.org", "ns1.example.org."},
+	{"MX", "10 mx1.example.com", "10 mx1.example.com."},
+	{"MX", "0 .", "0 ."},
+	{"SRV", "10 5 5060 sip.example.com", "10 5 5060 sip.example.com."},
+	{"A", "192.0.2.1", "192.0.2.1"},
+	{"TXT", `"a\"b" "c\011d"`, `"a\"bc\009d"`},
+	{"TXT", `"caf\303\251"`, `"caf\195\169"`},
+}
+
+func TestZoneFileValue(t *testing.T) {
+	for _, tt := range zoneFileValueTests {
+		if got := zoneFileValue(tt.typ, tt.value); got != tt.want {
+			t.Errorf("%s %q: got %q, want %q", tt.typ, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRoute53ZoneFileRoundTrip(t *testing.T) {
+	sets := []*route53.ResourceRecordSet{
+		{Name: aws.String("example.com."), Type: aws.String("MX"), TTL: aws.Int64(300), ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("10 mx1.example.com.")}, {Value: aws.String("20 mx2.example.com.")}}},
+		{Name: aws.String("example.com."), Type: aws.String("TXT"), TTL: aws.Int64(600), ResourceRecords: []*route53.ResourceRecord{{Value: aws.String(`"caf\303\251 \"quoted\""`)}}},
+		{Name: aws.String("\\052.example.com."), Type: aws.String("A"), TTL: aws.Int64(60), ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("192.0.2.1")}}},
+		{Name: aws.String("lb.example.com."), Type: aws.String("A"), AliasTarget: &route53.AliasTarget{DNSName: aws.String("lb.elb.amazonaws.com."), HostedZoneId: aws.String("Z1")}},
+	}
+
+	b := &bytes.Buffer{}
+	zoneFileHeader(b, "route53", "example.com", "Z0")
+	writeRoute53Records(b, sets)
+
+	records, commented, err := parseZoneFile(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []record{
+		{Name: "example.com.", Type: "MX", TTL: 300, Value: []string{"10 mx1.example.com."}},
+		{Name: "example.com.", Type: "MX", TTL: 300, Value: []string{"20 mx2.example.com."}},
+		{Name: "example.com.", Type: "TXT", TTL: 600, Value: []string{`"caf\303\251 \"quoted\""`}},
+		{Name: "*.example.com.", Type: "A", TTL: 60, Value: []string{"192.0.2.1"}},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %+v, want %+v", records, want)
+	}
+	if len(commented) != 1 || !strings.Contains(commented[0], "ALIAS") {
+		t.Errorf("got commented %q, want the alias", commented)
+	}
+}
+
+func TestCloudflareZoneFileRoundTrip(t *testing.T) {
+	records := []cloudflare.DNSRecord{
+		{Name: "www.example.com", Type: "CNAME", TTL: 1, Content: "web.example.net", Proxied: true},
+		{Name: "example.com", Type: "MX", TTL: 3600, Content: "mx1.example.com", Priority: 10},
+		{Name: "example.com", Type: "TXT", TTL: 1, Content: "v=spf1 include:_spf.example.com -all"},
+		{Name: "old.example.com", Type: "A", TTL: 120, Content: "192.0.2.1"},
+	}
+
+	b := &bytes.Buffer{}
+	zoneFileHeader(b, "cloudflare", "example.com", "0")
+	writeCloudflareRecords(b, records)
+
+	if strings.Contains(b.String(), "\t1\tIN\t") {
+		t.Errorf("automatic TTL written literally:\n%s", b)
+	}
+
+	got, _, err := parseZoneFile(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []record{
+		{Name: "www.example.com.", Type: "CNAME", TTL: autoTTL, Value: []string{"web.example.net."}, Proxied: true},
+		{Name: "example.com.", Type: "MX", TTL: 3600, Value: []string{"10 mx1.example.com."}},
+		{Name: "example.com.", Type: "TXT", TTL: autoTTL, Value: []string{`"v=spf1 include:_spf.example.com -all"`}},
+		{Name: "old.example.com.", Type: "A", TTL: 120, Value: []string{"192.0.2.1"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+)
+
+// geoSet is a geolocation routed record set, with the answer each location
+// gets from route53 and will get from cloudflare once migrated.
+type geoSet struct {
+	Name  string
+	Type  string
+	Rules []geoRule
+}
+
+type geoRule struct {
+	Location string
+	Route53  string
+	After    string
+}
+
+// continentNames are the route53 continent codes spelled out for readers
+// who don't know them.
+var continentNames = map[string]string{
+	"AF": "Africa",
+	"AN": "Antarctica",
+	"AS": "Asia",
+	"EU": "Europe",
+	"NA": "North America",
+	"OC": "Oceania",
+	"SA": "South America",
+}
+
+// geoLocation describes the location of a geolocation rule.
+func geoLocation(g *route53.GeoLocation) string {
+	switch {
+	case g.ContinentCode != nil:
+		if name, ok := continentNames[*g.ContinentCode]; ok {
+			return name
+		}
+		return "continent " + *g.ContinentCode
+	case aws.StringValue(g.CountryCode) == "*":
+		return "everywhere else"
+	case g.SubdivisionCode != nil:
+		return fmt.Sprintf("country %s, subdivision %s", aws.StringValue(g.CountryCode), *g.SubdivisionCode)
+	}
+
+	return "country " + aws.StringValue(g.CountryCode)
+}
+
+// newGeoSet lists the answers of the geolocation record sets sharing a
+// name and type, balanced telling whether they migrate as a load balancer.
+func newGeoSet(sets []*route53.ResourceRecordSet, balanced bool) geoSet {
+	g := geoSet{Name: *sets[0].Name, Type: *sets[0].Type}
+
+	for _, r := range sets {
+		answer := make([]string, 0, len(r.ResourceRecords))
+		if r.AliasTarget != nil {
+			answer = append(answer, "alias "+strings.TrimSuffix(*r.AliasTarget.DNSName, "."))
+		}
+		for _, rr := range r.ResourceRecords {
+			answer = append(answer, *rr.Value)
+		}
+
+		rule := geoRule{
+			Location: geoLocation(r.GeoLocation),
+			Route53:  strings.Join(answer, ", "),
+			After:    "no answer, not migrated",
+		}
+		if balanced {
+			rule.After = "the same, from the load balancer"
+			if regions, _ := geoRegions(r.GeoLocation); len(regions) > 0 {
+				rule.After += " regions " + strings.Join(regions, ", ")
+			} else {
+				rule.After += " fallback pool"
+			}
+		}
+
+		g.Rules = append(g.Rules, rule)
+	}
+
+	return g
+}
+
+// renderGeoTables writes a table per geolocation routed set of a zone, of
+// the answer each location gets before and after the migration.
+func renderGeoTables(b *bytes.Buffer, z *zone) {
+	for _, g := range z.geo {
+		fmt.Fprintf(b, "<p>Geolocation routing of %s %s:</p>\n", html.EscapeString(g.Name), html.EscapeString(g.Type))
+		fmt.Fprintf(b, "<table border=\"1\" cellpadding=\"4\" cellspacing=\"0\">\n<tr><th>Location</th><th>Route53 answer</th><th>Cloudflare answer after migration</th></tr>\n")
+		for _, r := range g.Rules {
+			fmt.Fprintf(b, "<tr><td>%s</td><td>%s</td><td>%s</td></tr>\n", html.EscapeString(r.Location), html.EscapeString(r.Route53), html.EscapeString(r.After))
+		}
+		fmt.Fprintf(b, "</table>\n")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().String("group", "", "Select the zones of this group of the config file instead of --domain, applying the group's defaults")
+	viper.BindPFlag("group", rootCmd.PersistentFlags().Lookup("group"))
+
+	configSetting("groups", false)
+}
+
+// zoneGroup is a named set of zones from the groups section of the config
+// file. Domains are zone names or patterns. Defaults override the config
+// file settings of the same name for the zones of the group, command line
+// flags still win. Setting email-to there sends the group its own report.
+//
+//	groups:
+//	  prod:
+//	    domains: [example.com, "*.example.net"]
+//	    defaults:
+//	      min-ttl: 300
+//	      email-to: [dns-prod@example.com]
+type zoneGroup struct {
+	Domains  []string
+	Defaults map[string]interface{}
+}
+
+// cmdFlags are the flags of the command being run, set before it runs.
+var cmdFlags *pflag.FlagSet
+
+// flagChanged reports whether a flag was set on the command line.
+func flagChanged(name string) bool {
+	return cmdFlags != nil && cmdFlags.Changed(name)
+}
+
+// loadGroup reads a zone group and applies its defaults to the settings.
+func loadGroup(name string) (*zoneGroup, error) {
+	key := "groups." + name
+	if !viper.IsSet(key) {
+		return nil, fmt.Errorf("Unknown zone group '%s'", name)
+	}
+
+	g := &zoneGroup{}
+	if err := viper.UnmarshalKey(key, g); err != nil {
+		return nil, fmt.Errorf("Invalid zone group '%s': %s", name, err)
+	}
+
+	if len(g.Domains) == 0 {
+		return nil, fmt.Errorf("Zone group '%s' has no domains", name)
+	}
+
+	for k, v := range g.Defaults {
+		switch {
+		case k == "group" || k == "domain":
+			return nil, errors.New("Zone group defaults can not set the group or domain")
+		case !flagChanged(k):
+			override(k, v)
+		}
+	}
+
+	return g, nil
+}
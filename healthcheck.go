@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	healthChecksCmd.Flags().Bool("create-monitors", false, "Create a Cloudflare monitor for each health check that has an equivalent")
+	viper.BindPFlag("create-monitors", healthChecksCmd.Flags().Lookup("create-monitors"))
+
+	rootCmd.AddCommand(healthChecksCmd)
+}
+
+var healthChecksCmd = &cobra.Command{
+	Use:   "health-checks",
+	Short: "List the Route53 health checks used by the zones and their Cloudflare monitors",
+	Long: `Lists the Route53 health checks referenced by the records of the selected
+zones, how each translates to a Cloudflare load balancer monitor and the
+monitor already created for it, if any. With --create-monitors the missing
+monitors are created. Load balancers created by migrate reuse them.`,
+	Run: doHealthChecks,
+}
+
+// healthRef is a record set using a health check.
+type healthRef struct {
+	CheckID       string
+	Name          string
+	Type          string
+	SetIdentifier string
+}
+
+func (r healthRef) String() string {
+	if r.SetIdentifier == "" {
+		return fmt.Sprintf("%s %s", r.Name, r.Type)
+	}
+	return fmt.Sprintf("%s %s %s", r.Name, r.Type, r.SetIdentifier)
+}
+
+// noMonitorError is returned for health checks cloudflare has no monitor
+// for.
+type noMonitorError struct {
+	typ string
+}
+
+func (e noMonitorError) Error() string {
+	return fmt.Sprintf("%s health checks have no cloudflare equivalent", e.typ)
+}
+
+// monitorDescription ties a cloudflare monitor to the health check it was
+// created from.
+func monitorDescription(checkID string) string {
+	return "route53 health check " + checkID
+}
+
+// healthMonitor translates a route53 health check into a cloudflare
+// monitor. Calculated and CloudWatch alarm checks have no equivalent.
+func healthMonitor(checkID string, hc *route53.HealthCheckConfig) (cloudflare.LoadBalancerMonitor, error) {
+	m := cloudflare.LoadBalancerMonitor{
+		Description:   monitorDescription(checkID),
+		Timeout:       5,
+		Retries:       int(aws.Int64Value(hc.FailureThreshold)),
+		Interval:      int(aws.Int64Value(hc.RequestInterval)),
+		Port:          uint16(aws.Int64Value(hc.Port)),
+		ExpectedCodes: "2xx",
+	}
+
+	switch *hc.Type {
+	case "HTTP", "HTTP_STR_MATCH":
+		m.Type = "http"
+	case "HTTPS", "HTTPS_STR_MATCH":
+		m.Type = "https"
+	case "TCP":
+		m.Type = "tcp"
+		return m, nil
+	default:
+		return m, noMonitorError{*hc.Type}
+	}
+
+	m.Method = "GET"
+	m.Path = aws.StringValue(hc.ResourcePath)
+	if m.Path == "" {
+		m.Path = "/"
+	}
+	m.ExpectedBody = aws.StringValue(hc.SearchString)
+	if hc.FullyQualifiedDomainName != nil {
+		m.Header = map[string][]string{"Host": {*hc.FullyQualifiedDomainName}}
+	}
+
+	return m, nil
+}
+
+// describeMonitor summarises a monitor for the report.
+func describeMonitor(m cloudflare.LoadBalancerMonitor) string {
+	if m.Type == "tcp" {
+		return fmt.Sprintf("tcp port %d every %ds", m.Port, m.Interval)
+	}
+
+	desc := fmt.Sprintf("%s %s %s every %ds expecting %s", m.Type, m.Method, m.Path, m.Interval, m.ExpectedCodes)
+	if m.ExpectedBody != "" {
+		desc += fmt.Sprintf(" and body %q", m.ExpectedBody)
+	}
+	return desc
+}
+
+// existingMonitors maps the health checks migrated before to the ID of
+// their monitor.
+func existingMonitors(cfg *config) (map[string]string, error) {
+	monitors, err := cfg.api.ListLoadBalancerMonitors()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list cloudflare monitors: %s", err)
+	}
+
+	ids := make(map[string]string)
+	for _, m := range monitors {
+		if strings.HasPrefix(m.Description, monitorDescription("")) {
+			ids[strings.TrimPrefix(m.Description, monitorDescription(""))] = m.ID
+		}
+	}
+
+	return ids, nil
+}
+
+// ensureMonitor returns the monitor of a health check, creating it when
+// there is none yet.
+func ensureMonitor(cfg *config, checkID string, monitors map[string]string) (string, error) {
+	if id, ok := monitors[checkID]; ok {
+		return id, nil
+	}
+
+	out, err := cfg.r53.GetHealthCheck(&route53.GetHealthCheckInput{HealthCheckId: aws.String(checkID)})
+	if err != nil {
+		return "", fmt.Errorf("Unable to read health check %s: %s", checkID, err)
+	}
+
+	m, err := healthMonitor(checkID, out.HealthCheck.HealthCheckConfig)
+	if err != nil {
+		return "", err
+	}
+
+	m, err = cfg.api.CreateLoadBalancerMonitor(m)
+	if err != nil {
+		return "", fmt.Errorf("Unable to create monitor for health check %s: %s", checkID, err)
+	}
+	monitors[checkID] = m.ID
+
+	return m.ID, nil
+}
+
+func doHealthChecks(cmd *cobra.Command, args []string) {
+	cfg, err := assembleConfig()
+	checkErr(err)
+
+	zones, err := findZones(cfg)
+	checkErr(err)
+
+	refs := make(map[string][]healthRef)
+	for _, z := range zones {
+		checkErr(fetchRoute53(cfg, z))
+		for _, r := range z.healthChecks {
+			refs[r.CheckID] = append(refs[r.CheckID], r)
+		}
+	}
+
+	if len(refs) == 0 {
+		fmt.Println("No health checks are used by the selected zones")
+		return
+	}
+
+	ids := make([]string, 0, len(refs))
+	for id := range refs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	monitors, err := existingMonitors(cfg)
+	checkErr(err)
+
+	create := viper.GetBool("create-monitors")
+	missing := 0
+	for _, id := range ids {
+		out, err := cfg.r53.GetHealthCheck(&route53.GetHealthCheckInput{HealthCheckId: aws.String(id)})
+		checkErr(err)
+		hc := out.HealthCheck.HealthCheckConfig
+
+		fmt.Printf("Health check %s (%s %s:%d%s)\n", id, *hc.Type, aws.StringValue(hc.FullyQualifiedDomainName),
+			aws.Int64Value(hc.Port), aws.StringValue(hc.ResourcePath))
+		for _, r := range refs[id] {
+			fmt.Printf("  used by %s\n", r)
+		}
+
+		m, err := healthMonitor(id, hc)
+		if err != nil {
+			fmt.Printf("  no monitor: %s\n", err)
+			continue
+		}
+		fmt.Printf("  monitor: %s\n", describeMonitor(m))
+
+		switch mid, ok := monitors[id]; {
+		case ok:
+			fmt.Printf("  cloudflare monitor %s\n", mid)
+		case create:
+			mid, err := ensureMonitor(cfg, id, monitors)
+			checkErr(err)
+			fmt.Printf("  created cloudflare monitor %s\n", mid)
+		default:
+			missing++
+		}
+	}
+
+	if missing > 0 {
+		fmt.Printf("%d monitors not created yet, run with --create-monitors to create them\n", missing)
+	}
+}
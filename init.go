@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/sts"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+}
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a config file, prompting for the credentials and checking them",
+	Long: `Prompts for the Cloudflare and AWS credentials, checks them against both
+APIs, optionally lists the hosted zones to pick a default domain from, and
+writes the config file: the --config file, or $HOME/.cfmigrate.yaml.`,
+	Args: cobra.NoArgs,
+	Run:  doInit,
+}
+
+// promptYes asks a yes or no question on the terminal.
+func promptYes(question string) (bool, error) {
+	for {
+		answer, err := promptValue(question+" [y/n]", false)
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// initCloudflare prompts for the cloudflare credentials until they work.
+func initCloudflare(settings yaml.MapSlice) (yaml.MapSlice, error) {
+	for {
+		cfg := &config{}
+
+		var err error
+		if cfg.cftoken, err = promptValue("Cloudflare API token (empty to use an email and API key)", true); err != nil {
+			return nil, err
+		}
+		if cfg.cftoken == "" {
+			if cfg.cfemail, err = promptValue("Cloudflare email", false); err != nil {
+				return nil, err
+			}
+			if cfg.cfkey, err = promptValue("Cloudflare API key", true); err != nil {
+				return nil, err
+			}
+		}
+
+		api, err := newCloudflare(cfg)
+		if err == nil {
+			var zones []cloudflare.Zone
+			if zones, err = api.ListZones(); err == nil {
+				fmt.Printf("Cloudflare credentials work, %d zones are visible\n", len(zones))
+
+				if cfg.cftoken != "" {
+					return append(settings, yaml.MapItem{Key: "cftoken", Value: cfg.cftoken}), nil
+				}
+				return append(settings,
+					yaml.MapItem{Key: "cfemail", Value: cfg.cfemail},
+					yaml.MapItem{Key: "cfkey", Value: cfg.cfkey},
+				), nil
+			}
+		}
+
+		fmt.Printf("Cloudflare rejected the credentials: %s\n", err)
+	}
+}
+
+// initAWS prompts for the AWS credentials until they work, returning the
+// session for listing the hosted zones.
+func initAWS(settings yaml.MapSlice) (yaml.MapSlice, *session.Session, error) {
+	for {
+		profile, err := promptValue("AWS profile (empty to enter access keys or use the default credentials)", false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		opts := session.Options{
+			Profile:                 profile,
+			SharedConfigState:       session.SharedConfigEnable,
+			AssumeRoleTokenProvider: mfaCode,
+		}
+
+		var key, secret string
+		if profile == "" {
+			if key, err = promptValue("AWS access key ID (empty for the default credentials)", false); err != nil {
+				return nil, nil, err
+			}
+			if key != "" {
+				if secret, err = promptValue("AWS secret access key", true); err != nil {
+					return nil, nil, err
+				}
+				opts.Config.Credentials = credentials.NewStaticCredentials(key, secret, "")
+			}
+		}
+
+		sess, err := session.NewSessionWithOptions(opts)
+		if err == nil {
+			var out *sts.GetCallerIdentityOutput
+			if out, err = sts.New(sess).GetCallerIdentity(&sts.GetCallerIdentityInput{}); err == nil {
+				fmt.Printf("AWS credentials work, signed in as %s\n", aws.StringValue(out.Arn))
+
+				switch {
+				case profile != "":
+					settings = append(settings, yaml.MapItem{Key: "aws-profile", Value: profile})
+				case key != "":
+					settings = append(settings,
+						yaml.MapItem{Key: "awskey", Value: key},
+						yaml.MapItem{Key: "awssecret", Value: secret},
+					)
+				}
+				return settings, sess, nil
+			}
+		}
+
+		fmt.Printf("AWS rejected the credentials: %s\n", err)
+	}
+}
+
+// initDomain lists the public hosted zones and asks for the default domain.
+func initDomain(settings yaml.MapSlice, sess *session.Session) (yaml.MapSlice, error) {
+	names := make([]string, 0)
+	err := route53.New(sess).ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(page *route53.ListHostedZonesOutput, lastPage bool) bool {
+		for _, hz := range page.HostedZones {
+			if !*hz.Config.PrivateZone {
+				names = append(names, strings.TrimSuffix(*hz.Name, "."))
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list the hosted zones: %s", err)
+	}
+
+	fmt.Println("Hosted zones:")
+	for _, n := range names {
+		fmt.Printf("  %s\n", n)
+	}
+
+	domain, err := promptValue("Default domain or pattern (empty for none)", false)
+	if err != nil || domain == "" {
+		return settings, err
+	}
+
+	return append(settings, yaml.MapItem{Key: "domain", Value: domain}), nil
+}
+
+func doInit(cmd *cobra.Command, args []string) {
+	if !isTerminal(os.Stdin) {
+		checkErr(errors.New("init requires an interactive terminal"))
+	}
+
+	file := cfgFile
+	if file == "" {
+		home, err := homedir.Dir()
+		checkErr(err)
+		file = filepath.Join(home, ".cfmigrate.yaml")
+	}
+
+	if _, err := os.Stat(file); err == nil {
+		ok, err := promptYes(fmt.Sprintf("%s exists, overwrite it?", file))
+		checkErr(err)
+		if !ok {
+			return
+		}
+	}
+
+	settings, err := initCloudflare(yaml.MapSlice{})
+	checkErr(err)
+
+	settings, sess, err := initAWS(settings)
+	checkErr(err)
+
+	list, err := promptYes("List the hosted zones to pick a default domain?")
+	checkErr(err)
+	if list {
+		settings, err = initDomain(settings, sess)
+		checkErr(err)
+	}
+
+	b, err := yaml.Marshal(settings)
+	checkErr(err)
+
+	checkErr(ioutil.WriteFile(file, b, 0600))
+	fmt.Printf("Wrote %s\n", file)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// placeholderPattern matches a placeholder in a record value, naming an
+// SSM parameter or Secrets Manager secret as --cf-key-from does:
+//
+//	{{ssm:///dns/prod/lb-target}}
+//	{{arn:aws:secretsmanager:us-east-1:123456789012:secret:lb-target}}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*((?:ssm://|arn:)[^{}\s]+)\s*\}\}`)
+
+// placeholderValues caches the placeholders resolved, each is read once a
+// run however many records use it.
+var placeholderValues = make(map[string]string)
+
+// interpolateRecords replaces the placeholders in the values of records
+// with what they name in AWS, so a zone file or transform can serve as a
+// template for several environments.
+func interpolateRecords(cfg *config, records []record) error {
+	for i, r := range records {
+		for j, v := range r.Value {
+			var err error
+			records[i].Value[j] = placeholderPattern.ReplaceAllStringFunc(v, func(p string) string {
+				ref := placeholderPattern.FindStringSubmatch(p)[1]
+				if value, ok := placeholderValues[ref]; ok {
+					return value
+				}
+
+				value, e := awsSecret(cfg.session, ref)
+				if e != nil {
+					err = fmt.Errorf("Unable to resolve %s %s: %s", r.Name, r.Type, e)
+					return p
+				}
+
+				placeholderValues[ref] = value
+				return value
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// reportVersion is bumped on incompatible changes of the JSON report, the
+// schema printed by the schema command describes the current version.
+// Version 2 requires manual in zones and proxied in sets.
+const reportVersion = 2
+
+// planVersion is bumped on incompatible changes of the JSON plan.
+const planVersion = 1
+
+type (
+	// jsonReport is the JSON output of compare
+	jsonReport struct {
+		Version int        `json:"version"`
+		Zones   []jsonZone `json:"zones"`
+	}
+
+	jsonZone struct {
+		Zone        string     `json:"zone"`
+		Stale       *time.Time `json:"stale,omitempty"`
+		Matching    int        `json:"matching"`
+		Diffs       []jsonDiff `json:"diffs"`
+		Conversions []string   `json:"conversions"`
+		Routed      []string   `json:"routed"`
+		Manual      []string   `json:"manual"`
+		Subzones    []jsonSet  `json:"subzones"`
+		Settling    []string   `json:"settling,omitempty"`
+	}
+
+	// jsonDiff is a record set differing between the providers, missing
+	// lists the route53 values cloudflare lacks and extra the values only
+	// cloudflare has
+	jsonDiff struct {
+		Name       string   `json:"name"`
+		Type       string   `json:"type"`
+		Route53    *jsonSet `json:"route53"`
+		Cloudflare *jsonSet `json:"cloudflare"`
+		Missing    []string `json:"missing"`
+		Extra      []string `json:"extra"`
+
+		// Route53Change is who last changed the route53 set and when, from
+		// CloudTrail
+		Route53Change string `json:"route53_change,omitempty"`
+	}
+
+	jsonSet struct {
+		Name    string   `json:"name"`
+		TTL     int      `json:"ttl"`
+		Values  []string `json:"values"`
+		Proxied bool     `json:"proxied"`
+	}
+
+	// jsonPlan is the plan migrate writes with --plan-json
+	jsonPlan struct {
+		Version int            `json:"version"`
+		Zones   []jsonPlanZone `json:"zones"`
+	}
+
+	jsonPlanZone struct {
+		Zone          string       `json:"zone"`
+		Changes       []jsonChange `json:"changes"`
+		LoadBalancers []string     `json:"load_balancers"`
+		Skipped       []string     `json:"skipped"`
+		Warnings      []string     `json:"warnings"`
+	}
+
+	jsonChange struct {
+		Action  string `json:"action"`
+		Name    string `json:"name"`
+		Type    string `json:"type"`
+		TTL     int    `json:"ttl"`
+		Value   string `json:"value"`
+		Proxied bool   `json:"proxied"`
+	}
+)
+
+// newJSONSet converts a record, nil for a set missing on one side.
+func newJSONSet(r *record) *jsonSet {
+	if r == nil {
+		return nil
+	}
+
+	return &jsonSet{Name: r.Name, TTL: r.TTL, Values: append([]string{}, r.Value...), Proxied: r.Proxied}
+}
+
+// renderJSON renders the compare results as a JSON report.
+func renderJSON(zones []*zone) ([]byte, error) {
+	report := jsonReport{Version: reportVersion, Zones: make([]jsonZone, 0, len(zones))}
+
+	for _, z := range zones {
+		jz := jsonZone{
+			Zone:        z.apex(),
+			Matching:    z.matching,
+			Diffs:       make([]jsonDiff, 0, len(z.diffs)),
+			Conversions: append([]string{}, z.conversions...),
+			Routed:      append([]string{}, z.routed...),
+			Manual:      append([]string{}, z.manual...),
+			Subzones:    make([]jsonSet, 0, len(z.subzones)),
+			Settling:    z.settling,
+		}
+		if !z.stale.IsZero() {
+			stale := z.stale
+			jz.Stale = &stale
+		}
+
+		for _, d := range z.diffs {
+			jd := jsonDiff{
+				Name:       d.Name,
+				Type:       d.Type,
+				Route53:    newJSONSet(d.Route53),
+				Cloudflare: newJSONSet(d.Cloudflare),
+				Missing:    []string{},
+				Extra:      []string{},
+
+				Route53Change: d.route53Change,
+			}
+
+			// values are normalized as splitValues does for sets on both sides
+			switch {
+			case d.Cloudflare == nil:
+				jd.Missing = normalizeValues(d.canonical, d.Type, d.Route53.Value)
+			case d.Route53 == nil:
+				jd.Extra = normalizeValues(d.canonical, d.Type, d.Cloudflare.Value)
+			default:
+				jd.Missing, jd.Extra = splitValues(d.canonical, d.Type, d.Route53.Value, d.Cloudflare.Value)
+			}
+
+			jz.Diffs = append(jz.Diffs, jd)
+		}
+
+		for _, r := range z.subzones {
+			jz.Subzones = append(jz.Subzones, *newJSONSet(&r))
+		}
+
+		report.Zones = append(report.Zones, jz)
+	}
+
+	return json.MarshalIndent(report, "", "  ")
+}
+
+// newJSONPlanZone converts the plan of a zone.
+func newJSONPlanZone(z *zone, plan []change, balancers []balancedSet, skipped []string) jsonPlanZone {
+	jz := jsonPlanZone{
+		Zone:          z.apex(),
+		Changes:       make([]jsonChange, 0, len(plan)),
+		LoadBalancers: make([]string, 0, len(balancers)),
+		Skipped:       append([]string{}, skipped...),
+		Warnings:      zoneWarnings(z, nil),
+	}
+
+	for _, c := range plan {
+		jz.Changes = append(jz.Changes, jsonChange{
+			Action:  c.Action,
+			Name:    c.Record.Name,
+			Type:    c.Record.Type,
+			TTL:     c.Record.TTL,
+			Value:   c.Value,
+			Proxied: c.Record.Proxied,
+		})
+	}
+
+	for _, b := range balancers {
+		jz.LoadBalancers = append(jz.LoadBalancers, b.String())
+	}
+
+	return jz
+}
+
+// renderPlanJSON renders the plans of the zones migrated as a JSON plan.
+func renderPlanJSON(zones []jsonPlanZone) ([]byte, error) {
+	if zones == nil {
+		zones = []jsonPlanZone{}
+	}
+
+	return json.MarshalIndent(jsonPlan{Version: planVersion, Zones: zones}, "", "  ")
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// validateSchema checks a decoded JSON value against the parts of JSON
+// Schema the schemas of the schema command use.
+func validateSchema(root, schema map[string]interface{}, v interface{}, path string) []string {
+	if ref, ok := schema["$ref"].(string); ok {
+		def := root["definitions"].(map[string]interface{})[strings.TrimPrefix(ref, "#/definitions/")]
+		if def == nil {
+			return []string{fmt.Sprintf("%s: unknown $ref %s", path, ref)}
+		}
+		return validateSchema(root, def.(map[string]interface{}), v, path)
+	}
+
+	if c, ok := schema["const"]; ok && c != v {
+		return []string{fmt.Sprintf("%s: %v is not %v", path, v, c)}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			found = found || e == v
+		}
+		if !found {
+			return []string{fmt.Sprintf("%s: %v is not one of %v", path, v, enum)}
+		}
+	}
+
+	if oneOf, ok := schema["oneOf"].([]interface{}); ok {
+		matches := 0
+		for _, s := range oneOf {
+			if len(validateSchema(root, s.(map[string]interface{}), v, path)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []string{fmt.Sprintf("%s: matches %d of oneOf", path, matches)}
+		}
+	}
+
+	errs := make([]string, 0)
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %v is not an object", path, v)}
+		}
+
+		properties, _ := schema["properties"].(map[string]interface{})
+		required, _ := schema["required"].([]interface{})
+		for _, r := range required {
+			if _, ok := obj[r.(string)]; !ok {
+				errs = append(errs, fmt.Sprintf("%s: %s is missing", path, r))
+			}
+		}
+		for k, pv := range obj {
+			ps, ok := properties[k]
+			if !ok {
+				if schema["additionalProperties"] == false {
+					errs = append(errs, fmt.Sprintf("%s: %s is not allowed", path, k))
+				}
+				continue
+			}
+			errs = append(errs, validateSchema(root, ps.(map[string]interface{}), pv, path+"."+k)...)
+		}
+	case "array":
+		list, ok := v.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: %v is not an array", path, v)}
+		}
+
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range list {
+				errs = append(errs, validateSchema(root, items, item, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return []string{fmt.Sprintf("%s: %v is not a string", path, v)}
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return []string{fmt.Sprintf("%s: %v is not an integer", path, v)}
+		}
+		if min, ok := schema["minimum"].(float64); ok && n < min {
+			return []string{fmt.Sprintf("%s: %v is below %v", path, v, min)}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []string{fmt.Sprintf("%s: %v is not a boolean", path, v)}
+		}
+	case "null":
+		if v != nil {
+			return []string{fmt.Sprintf("%s: %v is not null", path, v)}
+		}
+	}
+
+	return errs
+}
+
+func checkSchema(t *testing.T, schema string, output []byte) {
+	var root map[string]interface{}
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		t.Fatalf("invalid schema: %s", err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(output, &v); err != nil {
+		t.Fatalf("invalid output: %s", err)
+	}
+
+	for _, err := range validateSchema(root, root, v, "$") {
+		t.Error(err)
+	}
+}
+
+// sampleZone is a compared zone with a set on each side, a set on both
+// sides and a subzone.
+func sampleZone() *zone {
+	return &zone{
+		name: "example.com",
+		awsRecordSet: []record{
+			{Name: "www.example.com.", Type: "CNAME", TTL: 300, Value: []string{"Web.Example.net."}},
+			{Name: "example.com.", Type: "TXT", TTL: 300, Value: []string{`"v=spf1 -all"`}},
+			{Name: "example.com.", Type: "MX", TTL: 300, Value: []string{"10 mx1.example.com."}},
+			{Name: "example.com.", Type: "MX", TTL: 600, Value: []string{"20 mx2.example.com."}},
+		},
+		cfRecordSet: []record{
+			{ID: "1", Name: "example.com", Type: "TXT", TTL: 300, Value: []string{"v=spf1 ~all"}},
+			{ID: "2", Name: "old.example.com", Type: "A", TTL: 1, Value: []string{"192.0.2.1"}, Proxied: true},
+		},
+		subzones: []record{
+			{Name: "dev.example.com", Type: "NS", TTL: 172800, Value: []string{"ns1.example.org."}},
+		},
+		conversions: []string{"api.example.com A alias to a load balancer"},
+		settling:    []string{"mail.example.com A"},
+		stale:       time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestReportSchema(t *testing.T) {
+	z := sampleZone()
+	z.diffs, z.matching = diffZone(z, false)
+	for i, d := range z.diffs {
+		if d.Route53 != nil {
+			z.diffs[i].route53Change = "admin on 2026-10-14T09:30:00Z"
+		}
+	}
+
+	out, err := renderJSON([]*zone{z, {name: "example.net"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkSchema(t, reportSchema, out)
+}
+
+func TestReportNormalizedValues(t *testing.T) {
+	z := sampleZone()
+	z.diffs, _ = diffZone(z, false)
+
+	out, err := renderJSON([]*zone{z})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report jsonReport
+	if err := json.Unmarshal(out, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range report.Zones[0].Diffs {
+		for _, v := range append(append([]string{}, d.Missing...), d.Extra...) {
+			if v != normalizeValue(nil, d.Type, v) {
+				t.Errorf("%s %s: value %q isn't normalized", d.Name, d.Type, v)
+			}
+		}
+	}
+}
+
+func TestPlanSchema(t *testing.T) {
+	z := sampleZone()
+	plan, skipped := planZone(z)
+	plan = append(plan, change{
+		Action: "update",
+		Record: cloudflare.DNSRecord{ID: "1", Name: "example.com", Type: "TXT", TTL: 1, Content: "v=spf1 -all", Proxied: false},
+		Value:  `"v=spf1 -all"`,
+	})
+	skipped = append(skipped, "bad.example.com AAAA: Invalid AAAA value '1.2.3.4'")
+
+	balancers := []balancedSet{{Name: "lb.example.com.", Type: "A", TTL: 60, Policy: "weighted"}}
+	zones := []jsonPlanZone{newJSONPlanZone(z, plan, balancers, skipped), newJSONPlanZone(&zone{name: "example.net"}, nil, nil, nil)}
+
+	out, err := renderPlanJSON(zones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSchema(t, planSchema, out)
+
+	out, err = renderPlanJSON(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSchema(t, planSchema, out)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	authCmd.AddCommand(authLoginCmd)
+	authCmd.AddCommand(authLogoutCmd)
+	rootCmd.AddCommand(authCmd)
+}
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage the credentials kept in the OS keychain",
+}
+
+var authLoginCmd = &cobra.Command{
+	Use:   "login",
+	Short: "Store Cloudflare and AWS credentials in the OS keychain",
+	Long: `Prompts for the Cloudflare API token, or email and API key, and the AWS
+access keys, and stores them in the macOS Keychain or the Secret Service
+keyring through secret-tool. Later runs read them from there when they are
+not given by flags, the environment, the config file or Vault. Leave a
+prompt empty to skip it. On macOS security prompts for the secrets itself,
+so they are never passed to it as arguments.`,
+	Args: cobra.NoArgs,
+	Run:  doAuthLogin,
+}
+
+var authLogoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the credentials stored in the OS keychain",
+	Args:  cobra.NoArgs,
+	Run:   doAuthLogout,
+}
+
+// keychainService names the keychain entries of cfmigrate.
+const keychainService = "cfmigrate"
+
+// keychainAccounts are the settings kept in the keychain, with their
+// prompts.
+var keychainAccounts = []struct {
+	key    string
+	prompt string
+	secret bool
+}{
+	{"cftoken", "Cloudflare API token", true},
+	{"cfemail", "Cloudflare email (with an API key instead of a token)", false},
+	{"cfkey", "Cloudflare API key", true},
+	{"awskey", "AWS access key ID", false},
+	{"awssecret", "AWS secret access key", true},
+}
+
+// keychainSet stores a value in the OS keychain. Secrets aren't stored
+// this way on macOS, where security only takes them as an argument, see
+// keychainPrompt.
+func keychainSet(account, value string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", account, "-w", value)
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "store", "--label", keychainService+" "+account, "service", keychainService, "account", account)
+		cmd.Stdin = strings.NewReader(value)
+	default:
+		return fmt.Errorf("No supported keychain on %s", runtime.GOOS)
+	}
+
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Unable to store %s in the keychain: %s %s", account, err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// keychainPrompt stores a secret in the macOS Keychain. With -w last and
+// no value security prompts for it on the terminal, which keeps it out of
+// the process list.
+func keychainPrompt(account string) error {
+	cmd := exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", account, "-w")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("Unable to store %s in the keychain: %s", account, err)
+	}
+
+	return nil
+}
+
+// keychainGet reads a value from the OS keychain, empty when there is none
+// or no keychain to read.
+func keychainGet(account string) string {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keychainService, "-a", account, "-w")
+	case "linux", "freebsd", "openbsd":
+		cmd = exec.Command("secret-tool", "lookup", "service", keychainService, "account", account)
+	default:
+		return ""
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// keychainDelete removes a value from the OS keychain.
+func keychainDelete(account string) {
+	switch runtime.GOOS {
+	case "darwin":
+		exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", account).Run()
+	case "linux", "freebsd", "openbsd":
+		exec.Command("secret-tool", "clear", "service", keychainService, "account", account).Run()
+	}
+}
+
+// keychainCredentials fills in the credentials not given otherwise from
+// the OS keychain.
+func keychainCredentials(cfg *config) {
+	if cfg.cftoken == "" && cfg.cfkey == "" && viper.GetString("cf-key-from") == "" && viper.GetString("cf-token-from") == "" {
+		if cfg.cftoken = keychainGet("cftoken"); cfg.cftoken == "" {
+			cfg.cfkey = keychainGet("cfkey")
+		}
+	}
+
+	if cfg.cfemail == "" && cfg.cfkey != "" {
+		cfg.cfemail = keychainGet("cfemail")
+	}
+
+	if cfg.awskey == "" && cfg.awsProfile == "" {
+		if key := keychainGet("awskey"); key != "" {
+			cfg.awskey, cfg.awssecret = key, keychainGet("awssecret")
+		}
+	}
+}
+
+// promptValue asks for a value on the terminal, without echoing secrets
+// where stty can turn echo off.
+func promptValue(prompt string, secret bool) (string, error) {
+	fmt.Printf("%s: ", prompt)
+
+	if secret {
+		stty := exec.Command("stty", "-echo")
+		stty.Stdin = os.Stdin
+		if stty.Run() == nil {
+			defer func() {
+				restore := exec.Command("stty", "echo")
+				restore.Stdin = os.Stdin
+				restore.Run()
+				fmt.Println()
+			}()
+		}
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+func doAuthLogin(cmd *cobra.Command, args []string) {
+	if !isTerminal(os.Stdin) {
+		checkErr(errors.New("Logging in requires an interactive terminal"))
+	}
+
+	stored := 0
+	for _, a := range keychainAccounts {
+		if a.secret && runtime.GOOS == "darwin" {
+			store, err := promptYes("Store the " + a.prompt)
+			checkErr(err)
+
+			if store {
+				checkErr(keychainPrompt(a.key))
+				stored++
+			}
+			continue
+		}
+
+		value, err := promptValue(a.prompt, a.secret)
+		checkErr(err)
+
+		if value == "" {
+			continue
+		}
+
+		checkErr(keychainSet(a.key, value))
+		stored++
+	}
+
+	fmt.Printf("Stored %d credentials in the keychain\n", stored)
+}
+
+func doAuthLogout(cmd *cobra.Command, args []string) {
+	for _, a := range keychainAccounts {
+		keychainDelete(a.key)
+	}
+
+	fmt.Println("Removed the credentials from the keychain")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// liveTimeout bounds each query against the cloudflare name servers.
+const liveTimeout = 5 * time.Second
+
+// nameServerResolver returns a resolver sending every query to ns.
+func nameServerResolver(ns string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			d := net.Dialer{Timeout: liveTimeout}
+			return d.DialContext(ctx, network, net.JoinHostPort(strings.TrimSuffix(ns, "."), "53"))
+		},
+	}
+}
+
+// liveAnswers queries the answers cloudflare's name servers publish for a
+// record set. Only A, AAAA and TXT sets are queried.
+func liveAnswers(r *net.Resolver, name, typ string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), liveTimeout)
+	defer cancel()
+
+	answers := make([]string, 0)
+	switch typ {
+	case "A", "AAAA":
+		addrs, err := r.LookupIPAddr(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			if (a.IP.To4() != nil) == (typ == "A") {
+				answers = append(answers, a.IP.String())
+			}
+		}
+	case "TXT":
+		txts, err := r.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range txts {
+			answers = append(answers, quoteTXT(t))
+		}
+	}
+
+	return answers, nil
+}
+
+// compareLive compares the route53 record sets against the answers
+// published by cloudflare, which differ from the API content for proxied
+// records. It returns a line per set that doesn't match.
+func compareLive(cfg *config, z *zone) ([]string, string, error) {
+	details, err := cfg.api.ZoneDetails(z.zoneID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(details.NameServers) == 0 {
+		return nil, "", fmt.Errorf("No cloudflare name servers for %s", z.apex())
+	}
+
+	ns := details.NameServers[0]
+	resolver := nameServerResolver(ns)
+
+	sets, keys := groupRecords(z.awsRecordSet)
+	cf, _ := groupRecords(z.cfRecordSet)
+
+	status.setPhase(z.apex(), "querying cloudflare name servers")
+
+	lines := make([]string, 0)
+	for _, k := range keys {
+		set := sets[k]
+		if set.Type != "A" && set.Type != "AAAA" && set.Type != "TXT" {
+			continue
+		}
+
+		answers, err := liveAnswers(resolver, set.Name, set.Type)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s %s: %s", set.Name, set.Type, err))
+			continue
+		}
+
+		// proxied records answer with cloudflare's addresses, not the origin
+		if c, ok := cf[k]; ok && c.Proxied && len(answers) > 0 {
+			continue
+		}
+
+		if !equalValues(z.canonical, set.Type, set.Value, answers) {
+			lines = append(lines, fmt.Sprintf("%s %s: expected %s, answered %s", set.Name, set.Type,
+				strings.Join(normalizeValues(z.canonical, set.Type, set.Value), ", "), strings.Join(normalizeValues(z.canonical, set.Type, answers), ", ")))
+		}
+	}
+
+	return lines, ns, nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// balancedSet is a route53 record set with a routing policy that is
+// migrated as a cloudflare load balancer. Failover sets get an origin pool
+// per set identifier, weighted sets share one pool of weighted origins and
+// geolocation sets get a pool per set steered to by region.
+type balancedSet struct {
+	Name   string
+	Type   string
+	TTL    int
+	Policy string
+	Pools  []balancedPool
+}
+
+// balancedPool is one set identifier of a balanced set.
+type balancedPool struct {
+	SetIdentifier string
+	Failover      string
+	Weight        int64
+	Regions       []string
+	HealthCheckID string
+	Addresses     []string
+}
+
+func (b balancedSet) String() string {
+	pools := make([]string, 0, len(b.Pools))
+	for _, p := range b.Pools {
+		desc := p.SetIdentifier
+		switch {
+		case p.Failover != "":
+			desc = strings.ToLower(p.Failover) + " " + desc
+		case b.Policy == "weighted":
+			desc = fmt.Sprintf("%s weight %d", desc, p.Weight)
+		case b.Policy == "geo" && len(p.Regions) == 0:
+			desc += " default"
+		case b.Policy == "geo":
+			desc += " regions " + strings.Join(p.Regions, ",")
+		}
+		if p.HealthCheckID != "" {
+			desc += " checked by " + p.HealthCheckID
+		}
+		pools = append(pools, fmt.Sprintf("%s: %s", desc, strings.Join(p.Addresses, ", ")))
+	}
+
+	return fmt.Sprintf("%s %s %d %s (%s)", b.Name, b.Type, b.TTL, b.Policy, strings.Join(pools, "; "))
+}
+
+// continentRegions maps route53 continent codes to cloudflare load balancer
+// regions.
+var continentRegions = map[string][]string{
+	"AF": {"NAF", "SAF"},
+	"AS": {"ME", "SAS", "SEAS", "NEAS"},
+	"EU": {"WEU", "EEU"},
+	"NA": {"WNAM", "ENAM"},
+	"OC": {"OC"},
+	"SA": {"NSAM", "SSAM"},
+}
+
+// geoRegions returns the cloudflare regions of a geolocation rule, none for
+// the default rule. Country and subdivision rules cut across cloudflare
+// regions and can't be translated.
+func geoRegions(g *route53.GeoLocation) ([]string, bool) {
+	if aws.StringValue(g.CountryCode) == "*" {
+		return nil, true
+	}
+
+	regions, ok := continentRegions[aws.StringValue(g.ContinentCode)]
+	return regions, ok
+}
+
+// balanceable tells whether the record sets sharing a name and type can be
+// migrated as a load balancer.
+func balanceable(sets []*route53.ResourceRecordSet) bool {
+	if sets[0].GeoLocation != nil {
+		for _, r := range sets {
+			if _, ok := geoRegions(r.GeoLocation); !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	return sets[0].Failover != nil || sets[0].Weight != nil
+}
+
+// newBalancedSet builds a balanced set from the route53 record sets sharing
+// a name and type. Alias targets become hostname origins.
+func newBalancedSet(sets []*route53.ResourceRecordSet) balancedSet {
+	b := balancedSet{
+		Name:   *sets[0].Name,
+		Type:   *sets[0].Type,
+		TTL:    aliasTTL,
+		Policy: "failover",
+	}
+	switch {
+	case sets[0].Weight != nil:
+		b.Policy = "weighted"
+	case sets[0].GeoLocation != nil:
+		b.Policy = "geo"
+	}
+
+	for _, r := range sets {
+		p := balancedPool{
+			SetIdentifier: *r.SetIdentifier,
+			Failover:      aws.StringValue(r.Failover),
+			Weight:        aws.Int64Value(r.Weight),
+			HealthCheckID: aws.StringValue(r.HealthCheckId),
+		}
+		if r.GeoLocation != nil {
+			p.Regions, _ = geoRegions(r.GeoLocation)
+		}
+
+		if r.AliasTarget != nil {
+			p.Addresses = []string{strings.TrimSuffix(*r.AliasTarget.DNSName, ".")}
+		} else {
+			b.TTL = int(aws.Int64Value(r.TTL))
+			for _, rr := range r.ResourceRecords {
+				p.Addresses = append(p.Addresses, *rr.Value)
+			}
+		}
+
+		b.Pools = append(b.Pools, p)
+	}
+
+	// the primary pool is tried first
+	sort.SliceStable(b.Pools, func(i, j int) bool { return b.Pools[i].Failover == "PRIMARY" })
+
+	return b
+}
+
+// planBalancers returns the balanced sets of a zone that don't have a load
+// balancer in cloudflare yet, adding the health checks they leave out to
+// the warnings of the zone.
+func planBalancers(cfg *config, z *zone) ([]balancedSet, error) {
+	if len(z.balanced) == 0 {
+		return nil, nil
+	}
+
+	existing, err := cfg.api.ListLoadBalancers(z.zoneID)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list load balancers of %s: %s", z.apex(), err)
+	}
+
+	names := make(map[string]bool)
+	for _, lb := range existing {
+		names[strings.ToLower(lb.Name)] = true
+	}
+
+	plan := make([]balancedSet, 0)
+	for _, b := range z.balanced {
+		if !names[strings.ToLower(strings.TrimSuffix(b.Name, "."))] {
+			plan = append(plan, b)
+		}
+	}
+
+	if len(plan) == 0 {
+		return plan, nil
+	}
+
+	monitors, err := existingMonitors(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range plan {
+		warnings, err := balancerWarnings(cfg, b, monitors)
+		if err != nil {
+			return nil, err
+		}
+		z.warnings = append(z.warnings, warnings...)
+	}
+
+	return plan, nil
+}
+
+// invalidPoolChars matches what cloudflare doesn't allow in pool names.
+var invalidPoolChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// poolName names the pool of a set identifier, pool names are unique per
+// account.
+func poolName(name, setIdentifier string) string {
+	return invalidPoolChars.ReplaceAllString(strings.TrimSuffix(name, ".")+"-"+setIdentifier, "-")
+}
+
+// balancerPools builds the origin pools of a balanced set, together with
+// the health check of each pool and warnings about health checks left out.
+// Route53 weights become origin weights relative to the heaviest set, as
+// cloudflare weights range from 0 to 1.
+func balancerPools(b balancedSet) ([]cloudflare.LoadBalancerPool, []string, []string) {
+	pools := make([]cloudflare.LoadBalancerPool, 0, len(b.Pools))
+	checks := make([]string, 0, len(b.Pools))
+	warnings := make([]string, 0)
+
+	if b.Policy == "weighted" {
+		var max int64
+		for _, p := range b.Pools {
+			if p.Weight > max {
+				max = p.Weight
+			}
+		}
+
+		pool := cloudflare.LoadBalancerPool{
+			Name:        poolName(b.Name, "weighted"),
+			Description: fmt.Sprintf("route53 %s %s weighted sets", b.Name, b.Type),
+			Enabled:     true,
+		}
+
+		check := ""
+		for _, p := range b.Pools {
+			// route53 spreads evenly when every weight is 0
+			weight := 1.0
+			if max > 0 {
+				weight = float64(p.Weight) / float64(max)
+			}
+
+			for i, addr := range p.Addresses {
+				pool.Origins = append(pool.Origins, cloudflare.LoadBalancerOrigin{
+					Name:    poolName(p.SetIdentifier, fmt.Sprint(i+1)),
+					Address: addr,
+					Enabled: true,
+					Weight:  weight,
+				})
+			}
+
+			// a pool has a single monitor
+			if check == "" {
+				check = p.HealthCheckID
+			} else if p.HealthCheckID != "" && p.HealthCheckID != check {
+				warnings = append(warnings, fmt.Sprintf("%s: only health check %s is migrated, the pool has one monitor for all sets", b.Name, check))
+			}
+		}
+
+		return append(pools, pool), append(checks, check), warnings
+	}
+
+	for _, p := range b.Pools {
+		pool := cloudflare.LoadBalancerPool{
+			Name:        poolName(b.Name, p.SetIdentifier),
+			Description: fmt.Sprintf("route53 %s %s set %s", b.Name, b.Type, p.SetIdentifier),
+			Enabled:     true,
+		}
+
+		for i, addr := range p.Addresses {
+			pool.Origins = append(pool.Origins, cloudflare.LoadBalancerOrigin{
+				Name:    fmt.Sprintf("%s-%d", pool.Name, i+1),
+				Address: addr,
+				Enabled: true,
+				Weight:  1,
+			})
+		}
+
+		pools = append(pools, pool)
+		checks = append(checks, p.HealthCheckID)
+	}
+
+	return pools, checks, warnings
+}
+
+// balancerWarnings lists the health checks of a balanced set cloudflare
+// won't monitor: those dropped for the single monitor of a pool and those
+// without an equivalent monitor.
+func balancerWarnings(cfg *config, b balancedSet, monitors map[string]string) ([]string, error) {
+	pools, checks, warnings := balancerPools(b)
+	for i, check := range checks {
+		if _, ok := monitors[check]; ok || check == "" {
+			continue
+		}
+
+		out, err := cfg.r53.GetHealthCheck(&route53.GetHealthCheckInput{HealthCheckId: aws.String(check)})
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read health check %s: %s", check, err)
+		}
+
+		if _, err := healthMonitor(check, out.HealthCheck.HealthCheckConfig); err != nil {
+			warnings = append(warnings, fmt.Sprintf("pool %s is created without a monitor, health check %s: %s", pools[i].Name, check, err))
+		}
+	}
+
+	return warnings, nil
+}
+
+// createBalancer creates the pools and load balancer of a balanced set,
+// reusing the monitors of health checks migrated before.
+func createBalancer(cfg *config, z *zone, b balancedSet) error {
+	pools, checks, _ := balancerPools(b)
+
+	monitors, err := existingMonitors(cfg)
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(pools))
+	for i, pool := range pools {
+		if checks[i] != "" {
+			// pools without a monitor were warned about by planBalancers
+			id, err := ensureMonitor(cfg, checks[i], monitors)
+			if _, ok := err.(noMonitorError); !ok && err != nil {
+				return err
+			}
+			pool.Monitor = id
+		}
+
+		created, err := cfg.api.CreateLoadBalancerPool(pool)
+		if err != nil {
+			return fmt.Errorf("Unable to create pool %s: %s", pool.Name, err)
+		}
+		ids = append(ids, created.ID)
+	}
+
+	lb := cloudflare.LoadBalancer{
+		Name:           strings.TrimSuffix(b.Name, "."),
+		Description:    "migrated from route53 " + b.Policy + " records",
+		TTL:            b.TTL,
+		DefaultPools:   ids,
+		FallbackPool:   ids[len(ids)-1],
+		SteeringPolicy: "off",
+	}
+
+	// unmatched regions go to the default rule's pool, without one route53
+	// gives no answer but cloudflare needs somewhere to send them
+	if b.Policy == "geo" {
+		lb.SteeringPolicy = "geo"
+		lb.RegionPools = make(map[string][]string)
+		for i, p := range b.Pools {
+			for _, region := range p.Regions {
+				lb.RegionPools[region] = append(lb.RegionPools[region], ids[i])
+			}
+			if len(p.Regions) == 0 {
+				lb.DefaultPools = []string{ids[i]}
+				lb.FallbackPool = ids[i]
+			}
+		}
+	}
+
+	_, err = cfg.api.CreateLoadBalancer(z.zoneID, lb)
+	return err
+}
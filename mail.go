@@ -0,0 +1,339 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	validateEmailCmd.Flags().StringSlice("dkim-selector", nil, "DKIM selector the zone is expected to publish (repeatable)")
+	viper.BindPFlag("dkim-selector", validateEmailCmd.Flags().Lookup("dkim-selector"))
+
+	rootCmd.AddCommand(validateEmailCmd)
+}
+
+var validateEmailCmd = &cobra.Command{
+	Use:   "validate-email",
+	Short: "Check the SPF, DMARC and DKIM records of the zones and their Cloudflare copies",
+	Long: `Checks that the Route53 zones publish an SPF record at the apex, a DMARC
+record and the DKIM selectors given with --dkim-selector, and that these and
+any other DKIM records under _domainkey parse. Each record must have been
+carried over to Cloudflare unchanged. Exits with an error when problems are
+found.`,
+	Run: doValidateEmail,
+}
+
+// spfLookupLimit is the number of DNS lookups receivers allow an SPF record
+// to take before failing it.
+const spfLookupLimit = 10
+
+// spfModifier matches the name= of an SPF modifier.
+var spfModifier = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_.-]*=`)
+
+// mailTexts returns the TXT texts, or CNAME targets, of a record set.
+func mailTexts(records []record, name, typ string) []string {
+	texts := make([]string, 0)
+	for _, r := range records {
+		if r.Type != typ || !equalNames(r.Name, name) {
+			continue
+		}
+		for _, v := range r.Value {
+			if typ == "TXT" {
+				texts = append(texts, parseTXT(v))
+			} else {
+				texts = append(texts, normalizeName(v))
+			}
+		}
+	}
+	sort.Strings(texts)
+
+	return texts
+}
+
+// withPrefix returns the texts starting with prefix, ignoring case.
+func withPrefix(texts []string, prefix string) []string {
+	out := make([]string, 0)
+	for _, t := range texts {
+		if strings.HasPrefix(strings.ToLower(t), strings.ToLower(prefix)) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// checkSPF returns the syntax problems of an SPF record.
+func checkSPF(text string) []string {
+	problems := make([]string, 0)
+	terms := strings.Fields(text)[1:]
+	lookups := 0
+
+	for i, term := range terms {
+		if spfModifier.MatchString(term) {
+			parts := strings.SplitN(term, "=", 2)
+			switch strings.ToLower(parts[0]) {
+			case "redirect":
+				lookups++
+				fallthrough
+			case "exp":
+				if parts[1] == "" {
+					problems = append(problems, fmt.Sprintf("%s has no domain", term))
+				}
+			}
+			continue
+		}
+
+		mech := strings.TrimLeft(term, "+-~?")
+		arg := ""
+		if j := strings.IndexAny(mech, ":/"); j >= 0 {
+			mech, arg = mech[:j], mech[j:]
+		}
+
+		switch mech = strings.ToLower(mech); mech {
+		case "all":
+			if arg != "" {
+				problems = append(problems, fmt.Sprintf("%s takes no argument", term))
+			}
+			if i < len(terms)-1 {
+				problems = append(problems, fmt.Sprintf("terms after %s are never evaluated", term))
+			}
+		case "include", "exists":
+			lookups++
+			if !strings.HasPrefix(arg, ":") || len(arg) == 1 {
+				problems = append(problems, fmt.Sprintf("%s has no domain", term))
+			}
+		case "a", "mx":
+			lookups++
+		case "ptr":
+			lookups++
+			problems = append(problems, fmt.Sprintf("%s is deprecated", term))
+		case "ip4", "ip6":
+			ip := strings.TrimPrefix(arg, ":")
+			if j := strings.Index(ip, "/"); j >= 0 {
+				if n, err := strconv.Atoi(ip[j+1:]); err != nil || n < 0 || (mech == "ip4" && n > 32) || n > 128 {
+					problems = append(problems, fmt.Sprintf("%s has an invalid prefix length", term))
+				}
+				ip = ip[:j]
+			}
+			parsed := net.ParseIP(ip)
+			if parsed == nil || (parsed.To4() != nil) != (mech == "ip4") {
+				problems = append(problems, fmt.Sprintf("%s is not a valid %s address", term, mech))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("unknown mechanism %s", term))
+		}
+	}
+
+	if lookups > spfLookupLimit {
+		problems = append(problems, fmt.Sprintf("takes %d DNS lookups, receivers fail records taking more than %d", lookups, spfLookupLimit))
+	}
+
+	return problems
+}
+
+// mailTags parses the tag=value list of DMARC and DKIM records, returning
+// the tags in order.
+func mailTags(text string) ([]string, map[string]string) {
+	names := make([]string, 0)
+	tags := make(map[string]string)
+	for _, t := range strings.Split(text, ";") {
+		parts := strings.SplitN(t, "=", 2)
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name == "" {
+			continue
+		}
+		value := ""
+		if len(parts) == 2 {
+			value = strings.TrimSpace(parts[1])
+		}
+		names = append(names, name)
+		tags[name] = value
+	}
+	return names, tags
+}
+
+// checkDMARC returns the syntax problems of a DMARC record.
+func checkDMARC(text string) []string {
+	problems := make([]string, 0)
+	names, tags := mailTags(text)
+
+	if len(names) == 0 || names[0] != "v" || tags["v"] != "DMARC1" {
+		problems = append(problems, "does not start with v=DMARC1")
+	}
+
+	if _, ok := tags["p"]; !ok {
+		problems = append(problems, "has no p= policy")
+	}
+	for _, name := range []string{"p", "sp"} {
+		if v, ok := tags[name]; ok && v != "none" && v != "quarantine" && v != "reject" {
+			problems = append(problems, fmt.Sprintf("%s=%s is not none, quarantine or reject", name, v))
+		}
+	}
+	for _, name := range []string{"adkim", "aspf"} {
+		if v, ok := tags[name]; ok && v != "r" && v != "s" {
+			problems = append(problems, fmt.Sprintf("%s=%s is not r or s", name, v))
+		}
+	}
+	if v, ok := tags["pct"]; ok {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 || n > 100 {
+			problems = append(problems, fmt.Sprintf("pct=%s is not between 0 and 100", v))
+		}
+	}
+	for _, name := range []string{"rua", "ruf"} {
+		v, ok := tags[name]
+		if !ok {
+			continue
+		}
+		for _, uri := range strings.Split(v, ",") {
+			if !strings.HasPrefix(strings.TrimSpace(uri), "mailto:") {
+				problems = append(problems, fmt.Sprintf("%s address %s is not a mailto: URI", name, uri))
+			}
+		}
+	}
+
+	return problems
+}
+
+// checkDKIM returns the syntax problems of a DKIM key record.
+func checkDKIM(text string) []string {
+	problems := make([]string, 0)
+	names, tags := mailTags(text)
+
+	if v, ok := tags["v"]; ok && (names[0] != "v" || v != "DKIM1") {
+		problems = append(problems, "v= is not DKIM1 or not the first tag")
+	}
+	if k, ok := tags["k"]; ok && k != "rsa" && k != "ed25519" {
+		problems = append(problems, fmt.Sprintf("unknown key type k=%s", k))
+	}
+
+	p, ok := tags["p"]
+	switch {
+	case !ok:
+		problems = append(problems, "has no p= public key")
+	case p == "":
+		problems = append(problems, "key is revoked (empty p=)")
+	default:
+		if _, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(p), "")); err != nil {
+			problems = append(problems, "p= is not valid base64")
+		}
+	}
+
+	return problems
+}
+
+// checkMail returns the problems of the mail records of a zone. The route53
+// records are checked, cloudflare must publish the same.
+func checkMail(z *zone, selectors []string) []string {
+	problems := make([]string, 0)
+	apex := normalizeName(z.apex())
+
+	compare := func(what string, r53, cf []string) {
+		if strings.Join(r53, "\n") != strings.Join(cf, "\n") {
+			problems = append(problems, fmt.Sprintf("%s not carried over: route53 has %q, cloudflare %q", what, r53, cf))
+		}
+	}
+
+	spf := withPrefix(mailTexts(z.awsRecordSet, apex, "TXT"), "v=spf1")
+	switch len(spf) {
+	case 0:
+		problems = append(problems, "SPF: no v=spf1 record at the apex")
+	case 1:
+		for _, p := range checkSPF(spf[0]) {
+			problems = append(problems, "SPF: "+p)
+		}
+	default:
+		problems = append(problems, "SPF: several v=spf1 records at the apex, receivers treat this as an error")
+	}
+	compare("SPF", spf, withPrefix(mailTexts(z.cfRecordSet, apex, "TXT"), "v=spf1"))
+
+	dmarcName := "_dmarc." + apex
+	dmarc := withPrefix(mailTexts(z.awsRecordSet, dmarcName, "TXT"), "v=DMARC1")
+	switch len(dmarc) {
+	case 0:
+		problems = append(problems, "DMARC: no v=DMARC1 record at "+dmarcName)
+	case 1:
+		for _, p := range checkDMARC(dmarc[0]) {
+			problems = append(problems, "DMARC: "+p)
+		}
+	default:
+		problems = append(problems, "DMARC: several v=DMARC1 records at "+dmarcName)
+	}
+	compare("DMARC", dmarc, withPrefix(mailTexts(z.cfRecordSet, dmarcName, "TXT"), "v=DMARC1"))
+
+	// every key published under _domainkey is checked, the expected
+	// selectors must be among them
+	keys := make(map[string]bool)
+	for _, s := range selectors {
+		keys[strings.ToLower(s)+"._domainkey."+apex] = true
+	}
+	for _, r := range z.awsRecordSet {
+		if name := normalizeName(r.Name); strings.HasSuffix(name, "._domainkey."+apex) {
+			keys[name] = true
+		}
+	}
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		what := "DKIM " + strings.TrimSuffix(name, "._domainkey."+apex)
+		txt := mailTexts(z.awsRecordSet, name, "TXT")
+		cname := mailTexts(z.awsRecordSet, name, "CNAME")
+
+		switch {
+		case len(cname) > 0:
+			// keys delegated to the mail provider are checked by it
+			compare(what, cname, mailTexts(z.cfRecordSet, name, "CNAME"))
+			continue
+		case len(txt) == 0:
+			problems = append(problems, what+": no key at "+name)
+			continue
+		}
+
+		for _, t := range txt {
+			for _, p := range checkDKIM(t) {
+				problems = append(problems, what+": "+p)
+			}
+		}
+		compare(what, txt, mailTexts(z.cfRecordSet, name, "TXT"))
+	}
+
+	return problems
+}
+
+func doValidateEmail(cmd *cobra.Command, args []string) {
+	selectors := viper.GetStringSlice("dkim-selector")
+
+	count := 0
+	runZones(func(cfg *config, z *zone) error {
+		if err := loadZone(cfg, z); err != nil {
+			return err
+		}
+
+		problems := checkMail(z, selectors)
+		count += len(problems)
+
+		fmt.Printf("Zone: %s\n", z.apex())
+		if len(problems) == 0 {
+			fmt.Println("  SPF, DMARC and DKIM records are valid and carried over")
+		}
+		for _, p := range problems {
+			fmt.Printf("  %s\n", p)
+		}
+
+		return nil
+	})
+
+	if count > 0 {
+		checkErr(fmt.Errorf("%d mail record problems found", count))
+	}
+}
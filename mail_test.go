@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckSPF(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"v=spf1 ip4:192.0.2.0/24 ip6:2001:db8::/32 include:_spf.example.com ~all", []string{}},
+		{"v=spf1 mx redirect=_spf.example.com", []string{}},
+		{"v=spf1 -all include:_spf.example.com", []string{"terms after -all are never evaluated"}},
+		{"v=spf1 all:x", []string{"all:x takes no argument"}},
+		{"v=spf1 include: -all", []string{"include: has no domain"}},
+		{"v=spf1 ptr -all", []string{"ptr is deprecated"}},
+		{"v=spf1 ip4:192.0.2.0/33 -all", []string{"ip4:192.0.2.0/33 has an invalid prefix length"}},
+		{"v=spf1 ip4:2001:db8::1 -all", []string{"ip4:2001:db8::1 is not a valid ip4 address"}},
+		{"v=spf1 ip6:192.0.2.1 -all", []string{"ip6:192.0.2.1 is not a valid ip6 address"}},
+		{"v=spf1 foo:bar -all", []string{"unknown mechanism foo:bar"}},
+		{"v=spf1 redirect=", []string{"redirect= has no domain"}},
+		{"v=spf1" + strings.Repeat(" include:_spf.example.com", 11) + " -all", []string{"takes 11 DNS lookups, receivers fail records taking more than 10"}},
+	}
+
+	for _, tt := range tests {
+		if got := checkSPF(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDMARC(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"v=DMARC1; p=reject; rua=mailto:dmarc@example.com; pct=100; adkim=s", []string{}},
+		{"p=none; v=DMARC1", []string{"does not start with v=DMARC1"}},
+		{"v=DMARC1; rua=mailto:dmarc@example.com", []string{"has no p= policy"}},
+		{"v=DMARC1; p=block; sp=none", []string{"p=block is not none, quarantine or reject"}},
+		{"v=DMARC1; p=none; aspf=x", []string{"aspf=x is not r or s"}},
+		{"v=DMARC1; p=none; pct=150", []string{"pct=150 is not between 0 and 100"}},
+		{"v=DMARC1; p=none; rua=mailto:a@example.com,https://example.com", []string{"rua address https://example.com is not a mailto: URI"}},
+	}
+
+	for _, tt := range tests {
+		if got := checkDMARC(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCheckDKIM(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"v=DKIM1; k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQC1", []string{}},
+		{"k=ed25519; p=11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=", []string{}},
+		{"p=MIGf MA0G", []string{}},
+		{"k=rsa; v=DKIM1; p=MIGf", []string{"v= is not DKIM1 or not the first tag"}},
+		{"v=DKIM1; k=dsa; p=MIGf", []string{"unknown key type k=dsa"}},
+		{"v=DKIM1; k=rsa", []string{"has no p= public key"}},
+		{"v=DKIM1; p=", []string{"key is revoked (empty p=)"}},
+		{"v=DKIM1; p=not base64!", []string{"p= is not valid base64"}},
+	}
+
+	for _, tt := range tests {
+		if got := checkDKIM(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
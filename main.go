@@ -1,16 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/route53"
 	cloudflare "github.com/cloudflare/cloudflare-go"
-	"github.com/davecgh/go-spew/spew"
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -28,15 +34,102 @@ func init() {
 	rootCmd.PersistentFlags().StringP("cfkey", "k", "", "Cloudflare API Key")
 	viper.BindPFlag("cfkey", rootCmd.PersistentFlags().Lookup("cfkey"))
 
+	rootCmd.PersistentFlags().String("cfkey-file", "", "File containing the Cloudflare API Key")
+	viper.BindPFlag("cfkey-file", rootCmd.PersistentFlags().Lookup("cfkey-file"))
+
+	// Cloudflare API token, instead of the email and global API key
+	rootCmd.PersistentFlags().String("cftoken", "", "Cloudflare API Token, used instead of the email and API Key (env CF_API_TOKEN)")
+	viper.BindPFlag("cftoken", rootCmd.PersistentFlags().Lookup("cftoken"))
+	viper.BindEnv("cftoken", "CF_API_TOKEN")
+
+	rootCmd.PersistentFlags().String("cftoken-file", "", "File containing the Cloudflare API Token")
+	viper.BindPFlag("cftoken-file", rootCmd.PersistentFlags().Lookup("cftoken-file"))
+
+	rootCmd.PersistentFlags().String("cf-account-id", "", "Cloudflare account the zones, load balancer pools and monitors are looked up in, when the credentials reach several")
+	viper.BindPFlag("cf-account-id", rootCmd.PersistentFlags().Lookup("cf-account-id"))
+
 	// AWS Key
-	rootCmd.PersistentFlags().StringP("awskey", "a", "", "AWS Key")
+	rootCmd.PersistentFlags().StringP("awskey", "a", "", "AWS Key (the default AWS credential chain is used when no key is given)")
 	viper.BindPFlag("awskey", rootCmd.PersistentFlags().Lookup("awskey"))
 
+	rootCmd.PersistentFlags().String("awskey-file", "", "File containing the AWS Key")
+	viper.BindPFlag("awskey-file", rootCmd.PersistentFlags().Lookup("awskey-file"))
+
 	// AWS Secret
 	rootCmd.PersistentFlags().StringP("awssecret", "s", "", "AWS Secret Key")
 	viper.BindPFlag("awssecret", rootCmd.PersistentFlags().Lookup("awssecret"))
 
-	rootCmd.PersistentFlags().StringVarP(&domain, "domain", "d", "", "Domain name to compare")
+	rootCmd.PersistentFlags().String("awssecret-file", "", "File containing the AWS Secret Key")
+	viper.BindPFlag("awssecret-file", rootCmd.PersistentFlags().Lookup("awssecret-file"))
+
+	rootCmd.PersistentFlags().String("aws-profile", "", "Named profile of the shared AWS credentials and config files to use")
+	viper.BindPFlag("aws-profile", rootCmd.PersistentFlags().Lookup("aws-profile"))
+
+	rootCmd.PersistentFlags().String("aws-region", "", "AWS region for the API endpoints, overriding the profile and environment")
+	viper.BindPFlag("aws-region", rootCmd.PersistentFlags().Lookup("aws-region"))
+
+	rootCmd.PersistentFlags().StringP("domain", "d", "", "Domain name to compare (glob patterns such as '*.example.com' select multiple zones)")
+	viper.BindPFlag("domain", rootCmd.PersistentFlags().Lookup("domain"))
+
+	rootCmd.PersistentFlags().String("hosted-zone-id", "", "Route53 hosted zone ID to use when several zones share the domain name")
+	viper.BindPFlag("hosted-zone-id", rootCmd.PersistentFlags().Lookup("hosted-zone-id"))
+
+	rootCmd.PersistentFlags().Duration("heartbeat", 0, "Log a heartbeat line at this interval (e.g. 1m), 0 disables")
+	viper.BindPFlag("heartbeat", rootCmd.PersistentFlags().Lookup("heartbeat"))
+
+	rootCmd.PersistentFlags().String("status-file", "", "Keep the current run phase in this JSON file for external monitoring")
+	viper.BindPFlag("status-file", rootCmd.PersistentFlags().Lookup("status-file"))
+
+	rootCmd.PersistentFlags().Bool("private", false, "Compare private hosted zones instead of public ones")
+	viper.BindPFlag("private", rootCmd.PersistentFlags().Lookup("private"))
+
+	rootCmd.PersistentFlags().String("cache-dir", "", "Cache fetched Cloudflare records in this directory")
+	viper.BindPFlag("cache-dir", rootCmd.PersistentFlags().Lookup("cache-dir"))
+
+	rootCmd.PersistentFlags().Bool("allow-stale", false, "Compare against cached Cloudflare records when Cloudflare is unavailable")
+	viper.BindPFlag("allow-stale", rootCmd.PersistentFlags().Lookup("allow-stale"))
+
+	rootCmd.PersistentFlags().Bool("flatten-aliases", false, "Resolve Route53 alias records to A/AAAA values instead of converting them to CNAMEs")
+	viper.BindPFlag("flatten-aliases", rootCmd.PersistentFlags().Lookup("flatten-aliases"))
+
+	rootCmd.PersistentFlags().Int("min-ttl", 0, "Raise lower Route53 TTLs to this value in Cloudflare")
+	viper.BindPFlag("min-ttl", rootCmd.PersistentFlags().Lookup("min-ttl"))
+
+	rootCmd.PersistentFlags().StringSlice("ttl-override", nil, "Use this TTL for every record of a type in Cloudflare, as TYPE=SECONDS or TYPE=auto (repeatable)")
+	viper.BindPFlag("ttl-override", rootCmd.PersistentFlags().Lookup("ttl-override"))
+
+	rootCmd.PersistentFlags().Bool("ignore-ttl", false, "Compare record values only, ignoring TTL differences")
+	viper.BindPFlag("ignore-ttl", rootCmd.PersistentFlags().Lookup("ignore-ttl"))
+
+	rootCmd.PersistentFlags().StringSlice("proxied", nil, "Create matching records proxied in Cloudflare: names relative to the zone, @ for the apex, globs allowed (repeatable, default DNS only)")
+	viper.BindPFlag("proxied", rootCmd.PersistentFlags().Lookup("proxied"))
+
+	rootCmd.PersistentFlags().Bool("live", false, "Also compare A, AAAA and TXT records against the answers of Cloudflare's name servers, in the text output")
+	viper.BindPFlag("live", rootCmd.PersistentFlags().Lookup("live"))
+
+	rootCmd.PersistentFlags().Bool("flatten-cnames", false, "Point CNAME chains within a zone straight at their final target")
+	viper.BindPFlag("flatten-cnames", rootCmd.PersistentFlags().Lookup("flatten-cnames"))
+
+	rootCmd.PersistentFlags().Bool("check-dangling", false, "Leave out CNAME records whose targets no longer exist, listing them for manual action")
+	viper.BindPFlag("check-dangling", rootCmd.PersistentFlags().Lookup("check-dangling"))
+
+	rootCmd.PersistentFlags().Bool("pick-routed", false, "Migrate the highest weight or primary record of weighted and failover record sets")
+	viper.BindPFlag("pick-routed", rootCmd.PersistentFlags().Lookup("pick-routed"))
+
+	rootCmd.PersistentFlags().Bool("create-load-balancers", false, "Migrate failover, weighted and continent geolocation record sets as Cloudflare load balancers")
+	viper.BindPFlag("create-load-balancers", rootCmd.PersistentFlags().Lookup("create-load-balancers"))
+
+	rootCmd.PersistentFlags().StringP("output", "o", "text", "Output format of compare: text, json or git-comment (markdown for a pull request)")
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+
+	rootCmd.PersistentFlags().String("github-pr", "", "Post the git-comment output to this GitHub pull request (owner/repo#number), using GITHUB_TOKEN")
+	viper.BindPFlag("github-pr", rootCmd.PersistentFlags().Lookup("github-pr"))
+
+	rootCmd.PersistentFlags().String("gitlab-mr", "", "Post the git-comment output to this GitLab merge request (project#iid), using GITLAB_TOKEN")
+	viper.BindPFlag("gitlab-mr", rootCmd.PersistentFlags().Lookup("gitlab-mr"))
+
+	rootCmd.PersistentFlags().String("subdomain", "", "Only compare records under this subdomain of --domain against its own Cloudflare zone")
+	viper.BindPFlag("subdomain", rootCmd.PersistentFlags().Lookup("subdomain"))
 }
 
 func main() {
@@ -45,33 +138,172 @@ func main() {
 
 var (
 	cfgFile string
-	domain  string
+
+	// stdin is shared by all interactive prompts
+	stdin = bufio.NewReader(os.Stdin)
 
 	// rootCmd represents the base command when called without any subcommands
 	rootCmd = &cobra.Command{
 		Use:   "cfmigrate",
 		Short: "A brief description of your application",
-		Long:  ``,
-		Run:   doCompare,
+		Long: `Compares the records of Route53 hosted zones with their Cloudflare zones.
+
+Settings are taken, in order of precedence, from command line flags, the
+defaults of the --group zone group, the --profile section of the config
+file, CFMIGRATE_ environment variables, the rest of the config file and
+the built in defaults. Environment variables are named
+after the flag in upper case with dashes as underscores, such as
+CFMIGRATE_CACHE_DIR for --cache-dir. The credentials are read from
+CFMIGRATE_CF_EMAIL, CFMIGRATE_CF_KEY, CFMIGRATE_AWS_KEY and
+CFMIGRATE_AWS_SECRET, while CF_API_TOKEN, GITHUB_TOKEN, GITLAB_TOKEN,
+VAULT_ADDR and VAULT_TOKEN keep their usual names.
+
+Secrets can also be read from files, such as mounted container secrets,
+named by a variable with a _FILE suffix: CFMIGRATE_CFKEY_FILE,
+CFMIGRATE_CFTOKEN_FILE, CFMIGRATE_AWSKEY_FILE, CFMIGRATE_AWSSECRET_FILE,
+CFMIGRATE_SMTP_PASSWORD_FILE, CFMIGRATE_GITHUB_TOKEN_FILE,
+CFMIGRATE_GITLAB_TOKEN_FILE and CFMIGRATE_VAULT_TOKEN_FILE.
+
+A config file encrypted with SOPS is decrypted in memory with the sops
+binary, which needs to be on the PATH with access to the keys.`,
+		Run: doCompare,
+
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			cmdFlags = cmd.Flags()
+			startUsage(cmd, args)
+		},
 	}
 )
 
 type (
 	record struct {
+		// ID is the cloudflare record ID, route53 records have none
+		ID    string
 		Name  string
 		Type  string
 		TTL   int
 		Value []string
+
+		// Proxied is whether cloudflare proxies the record, or for route53
+		// records whether the --proxied rules will have it proxied
+		Proxied bool
+
+		// Modified is when cloudflare last changed the record, route53
+		// keeps no such time
+		Modified time.Time
+	}
+
+	// zone holds the state of a single domain being compared
+	zone struct {
+		name         string
+		hostedZoneID string
+		zoneID       string
+		awsRecordSet []record
+		cfRecordSet  []record
+
+		// subdomain, when set, is split out of the route53 zone into its own
+		// cloudflare zone. delegation holds the NS values route53 currently
+		// serves for it.
+		subdomain  string
+		delegation []string
+
+		// subzones are the NS record sets delegating names below the zone
+		// apex, they are migrated like other records but reported apart
+		subzones []record
+
+		// conversions describes each alias record rewritten into a value
+		// cloudflare can serve
+		conversions []string
+
+		// transformed describes each record rewritten by the transforms of
+		// the config file
+		transformed []string
+
+		// canonical are the canonicalize rules of the config file the
+		// values of both providers are compared with
+		canonical canonicalRules
+
+		// chains lists the CNAME records pointing at other CNAME records
+		// of the zone
+		chains []string
+
+		// problems are duplicate, conflicting and shadowing records found
+		// in either provider
+		problems []string
+
+		// settling lists the differing record sets changed within the
+		// --settle window, left out of the differences
+		settling []string
+
+		// timeline lists the recent changes of the zone, of cloudflare
+		// from its audit log and of route53 from CloudTrail, newest first
+		timeline []string
+
+		// routed describes how the record sets with routing policies are
+		// migrated
+		routed []string
+
+		// manual lists what can't be translated and needs manual action:
+		// unsupported types, unconvertible aliases and routing policies
+		manual []string
+
+		// warnings are what planning found cloudflare won't serve or check
+		// as route53 does, such as health checks left out of load balancers
+		warnings []string
+
+		// geo are the geolocation routed record sets, for the report
+		geo []geoSet
+
+		// healthChecks are the record sets using route53 health checks
+		healthChecks []healthRef
+
+		// balanced are the routing policy record sets migrated as
+		// cloudflare load balancers
+		balanced []balancedSet
+
+		// diffs is the result of comparing the zone, matching the number of
+		// record sets that are the same in both providers
+		diffs    []recordDiff
+		matching int
+
+		// stale is when the cached cloudflare records in use were fetched,
+		// it is zero for live data
+		stale time.Time
 	}
 
 	config struct {
 		cfemail      string
 		cfkey        string
+		cftoken      string
+		cfAccountID  string
 		awskey       string
 		awssecret    string
+		awstoken     string
+		awsProfile   string
+		awsRegion    string
 		domain       string
-		awsRecordSet []record
-		cfRecordSet  []record
+		hostedZoneID string
+
+		// group is the zone group selected instead of a domain
+		group *zoneGroup
+
+		subdomain    string
+		private      bool
+		cacheDir     string
+		allowStale   bool
+		flatten      bool
+		flattenChain bool
+		dangling     bool
+		pickRouted   bool
+		ttl          ttlPolicy
+		proxied      proxyRules
+		tagPolicies  []tagPolicy
+		canonical    canonicalRules
+		transforms   []transform
+		ignoreTTL    bool
+		live         bool
+		balance      bool
+		output       string
 		session      *session.Session
 		r53          *route53.Route53
 		api          *cloudflare.API
@@ -85,6 +317,8 @@ func Execute() {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+
+	reportUsage(nil)
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -104,55 +338,255 @@ func initConfig() {
 		viper.AddConfigPath(home)
 		viper.AddConfigPath(".")
 		viper.SetConfigName("cfmigrate")
+
+		// the file init writes
+		if _, err := os.Stat(filepath.Join(home, ".cfmigrate.yaml")); err == nil {
+			viper.SetConfigFile(filepath.Join(home, ".cfmigrate.yaml"))
+		}
 	}
 
-	viper.AutomaticEnv() // read in environment variables that match
+	viper.SetEnvPrefix("cfmigrate")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	viper.AutomaticEnv() // read in environment variables that match, e.g. CFMIGRATE_CACHE_DIR for cache-dir
 
-	// If a config file is found, read it in.
+	// credentials read from names spelled out in full, and tokens from the
+	// names other tools use
+	viper.BindEnv("cfemail", "CFMIGRATE_CF_EMAIL")
+	viper.BindEnv("cfkey", "CFMIGRATE_CF_KEY")
+	viper.BindEnv("awskey", "CFMIGRATE_AWS_KEY")
+	viper.BindEnv("awssecret", "CFMIGRATE_AWS_SECRET")
+	viper.BindEnv("github-token", "GITHUB_TOKEN")
+	viper.BindEnv("gitlab-token", "GITLAB_TOKEN")
+	viper.BindEnv("vault-addr", "VAULT_ADDR")
+	viper.BindEnv("vault-token", "VAULT_TOKEN")
+
+	// If a config file is found, read it in. Stdout is left to the
+	// output, such as JSON, so the notice goes to stderr.
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
+
+		if err := decryptConfig(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 	}
 }
 
+// secretValue returns the value of key, or the contents of the file named
+// by key-file so secrets can be mounted instead of passed on the command
+// line or in the environment.
+func secretValue(key string) (string, error) {
+	if v := viper.GetString(key); v != "" {
+		return v, nil
+	}
+
+	file := viper.GetString(key + "-file")
+	if file == "" {
+		return "", nil
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("Unable to read %s file: %s", key, err)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
 func assembleConfig() (*config, error) {
+	// the profile and group defaults apply before any setting is read
+	if name := viper.GetString("profile"); name != "" {
+		if strings.Contains(name, ",") {
+			return nil, errors.New("Several profiles can only be used by the commands running over zones")
+		}
+		if err := loadProfile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var group *zoneGroup
+	if name := viper.GetString("group"); name != "" {
+		var err error
+		if group, err = loadGroup(name); err != nil {
+			return nil, err
+		}
+	}
+
+	cfkey, err := secretValue("cfkey")
+	if err != nil {
+		return nil, err
+	}
+
+	cftoken, err := secretValue("cftoken")
+	if err != nil {
+		return nil, err
+	}
+
+	awskey, err := secretValue("awskey")
+	if err != nil {
+		return nil, err
+	}
+
+	awssecret, err := secretValue("awssecret")
+	if err != nil {
+		return nil, err
+	}
+
 	cfg := &config{
 		cfemail:      viper.GetString("cfemail"),
-		cfkey:        viper.GetString("cfkey"),
-		awskey:       viper.GetString("awskey"),
-		awssecret:    viper.GetString("awssecret"),
-		domain:       domain,
-		awsRecordSet: make([]record, 0),
-		cfRecordSet:  make([]record, 0),
+		cfkey:        cfkey,
+		cftoken:      cftoken,
+		cfAccountID:  viper.GetString("cf-account-id"),
+		awskey:       awskey,
+		awssecret:    awssecret,
+		awsProfile:   viper.GetString("aws-profile"),
+		awsRegion:    viper.GetString("aws-region"),
+		domain:       viper.GetString("domain"),
+		hostedZoneID: viper.GetString("hosted-zone-id"),
+		group:        group,
+		subdomain:    strings.TrimSuffix(viper.GetString("subdomain"), "."),
+		private:      viper.GetBool("private"),
+		cacheDir:     viper.GetString("cache-dir"),
+		allowStale:   viper.GetBool("allow-stale"),
+		flatten:      viper.GetBool("flatten-aliases"),
+		flattenChain: viper.GetBool("flatten-cnames"),
+		dangling:     viper.GetBool("check-dangling"),
+		pickRouted:   viper.GetBool("pick-routed"),
+		balance:      viper.GetBool("create-load-balancers"),
+		output:       viper.GetString("output"),
+		ignoreTTL:    viper.GetBool("ignore-ttl"),
+		live:         viper.GetBool("live"),
 	}
 
-	if cfg.cfemail == "" {
-		return nil, errors.New("No cloudflare email supplied")
+	if err := vaultCredentials(cfg); err != nil {
+		return nil, err
 	}
 
-	if cfg.cfkey == "" {
-		return nil, errors.New("No cloudflare api key supplied")
+	keychainCredentials(cfg)
+
+	cfg.ttl, err = parseTTLPolicy(viper.GetInt("min-ttl"), viper.GetStringSlice("ttl-override"))
+	if err != nil {
+		return nil, err
 	}
 
-	if cfg.awskey == "" {
-		return nil, errors.New("No AWS key supplied")
+	cfg.proxied, err = parseProxyRules(viper.GetStringSlice("proxied"))
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.tagPolicies, err = loadTagPolicies()
+	if err != nil {
+		return nil, err
 	}
 
-	if cfg.awssecret == "" {
+	cfg.transforms, err = loadTransforms()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.canonical, err = loadCanonicalRules()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.awskey != "" && cfg.awssecret == "" {
 		return nil, errors.New("No AWS Secret Key supplied")
 	}
 
-	if cfg.domain == "" {
+	if cfg.awskey == "" && cfg.awssecret != "" {
+		return nil, errors.New("No AWS key supplied")
+	}
+
+	if cfg.awskey != "" && cfg.awsProfile != "" {
+		return nil, errors.New("An AWS profile can not be used with an AWS key")
+	}
+
+	if cfg.domain == "" && cfg.group == nil {
 		return nil, errors.New("No domain name supplied")
 	}
 
-	sess := session.New(&aws.Config{
-		Credentials: credentials.NewStaticCredentials(cfg.awskey, cfg.awssecret, ""),
-	})
+	if cfg.group != nil && (cfg.domain != "" || cfg.hostedZoneID != "" || cfg.subdomain != "") {
+		return nil, errors.New("A zone group can not be used with a domain, hosted zone ID or subdomain")
+	}
+
+	if cfg.hostedZoneID != "" && isGlob(cfg.domain) {
+		return nil, errors.New("A hosted zone ID can not be used with a domain pattern")
+	}
+
+	if cfg.output != "text" && cfg.output != "git-comment" && cfg.output != "json" {
+		return nil, fmt.Errorf("Unknown output format '%s'", cfg.output)
+	}
+
+	if cfg.live && cfg.output != "text" {
+		return nil, errors.New("Live answers are only compared in the text output")
+	}
+
+	if cfg.allowStale && cfg.cacheDir == "" {
+		return nil, errors.New("Using stale cloudflare data requires a cache directory")
+	}
+
+	if cfg.subdomain != "" {
+		if isGlob(cfg.domain) {
+			return nil, errors.New("A subdomain can not be used with a domain pattern")
+		}
+
+		if !inZone(cfg.subdomain, cfg.domain) || equalNames(cfg.subdomain, cfg.domain) {
+			return nil, fmt.Errorf("Subdomain '%s' is not part of '%s'", cfg.subdomain, cfg.domain)
+		}
+	}
+
+	// without explicit keys the default chain applies: environment,
+	// shared credentials file, then the instance or container role
+	opts := session.Options{
+		Profile:                 cfg.awsProfile,
+		SharedConfigState:       session.SharedConfigEnable,
+		AssumeRoleTokenProvider: mfaCode,
+	}
+	if cfg.awsRegion != "" {
+		opts.Config.Region = aws.String(cfg.awsRegion)
+	}
+	if cfg.awskey != "" {
+		opts.Config.Credentials = credentials.NewStaticCredentials(cfg.awskey, cfg.awssecret, cfg.awstoken)
+	}
+
+	sess, err := session.NewSessionWithOptions(opts)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to set up the AWS session: %s", err)
+	}
+
+	if sess, err = mfaSession(sess); err != nil {
+		return nil, err
+	}
 
 	cfg.session = sess
 	cfg.r53 = route53.New(cfg.session)
 
-	api, err := cloudflare.New(cfg.cfkey, cfg.cfemail)
+	// cloudflare credentials may be kept in AWS, read with the session
+	if ref := viper.GetString("cf-key-from"); ref != "" {
+		if cfg.cfkey, err = awsSecret(sess, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	if ref := viper.GetString("cf-token-from"); ref != "" {
+		if cfg.cftoken, err = awsSecret(sess, ref); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.cftoken != "" && cfg.cfkey != "" {
+		return nil, errors.New("Use either a cloudflare api token or an api key, not both")
+	}
+
+	if cfg.cftoken == "" && cfg.cfemail == "" {
+		return nil, errors.New("No cloudflare email supplied")
+	}
+
+	if cfg.cftoken == "" && cfg.cfkey == "" {
+		return nil, errors.New("No cloudflare api key or token supplied")
+	}
+
+	api, err := newCloudflare(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -164,65 +598,468 @@ func assembleConfig() (*config, error) {
 
 func checkErr(err error) {
 	if err != nil {
+		reportUsage(err)
+		status.fail(err)
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
-func doCompare(cmd *cobra.Command, args []string) {
-	cfg, err := assembleConfig()
-	checkErr(err)
+// isGlob reports whether the domain contains glob pattern characters.
+func isGlob(domain string) bool {
+	return strings.ContainsAny(domain, "*?[")
+}
+
+// findZones returns the hosted zones in route53 matching the configured
+// domain, which may be a glob pattern. Only public zones are considered
+// unless --private was given, in which case only private zones are.
+func findZones(cfg *config) ([]*zone, error) {
+	zones := make([]*zone, 0)
+
+	if cfg.group == nil && !isGlob(cfg.domain) {
+		hzid, err := findHostedZoneID(cfg)
+		if err != nil {
+			return nil, err
+		}
 
-	// verify domain exists in route53
-	var hzid string
+		return append(zones, &zone{name: cfg.domain, hostedZoneID: hzid, subdomain: cfg.subdomain}), nil
+	}
+
+	patterns := []string{cfg.domain}
+	if cfg.group != nil {
+		patterns = cfg.group.Domains
+	}
+
+	// validate the patterns up front, path.Match only reports bad patterns
+	// when it reaches the malformed part of the pattern
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("Invalid domain pattern '%s': %s", p, err)
+		}
+	}
+
+	err := cfg.r53.ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(page *route53.ListHostedZonesOutput, lastPage bool) bool {
+		for _, hz := range page.HostedZones {
+			if *hz.Config.PrivateZone != cfg.private {
+				continue
+			}
+
+			name := strings.TrimSuffix(*hz.Name, ".")
+			for _, p := range patterns {
+				if ok, _ := path.Match(p, name); ok {
+					zones = append(zones, &zone{name: name, hostedZoneID: *hz.Id})
+					break
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(zones) == 0 {
+		return nil, fmt.Errorf("No route53 domains match '%s'", strings.Join(patterns, "', '"))
+	}
+
+	return zones, nil
+}
+
+// findHostedZoneID resolves the hosted zone for a single domain. When
+// several zones share the name the user is asked to pick one, unless
+// --hosted-zone-id was given.
+func findHostedZoneID(cfg *config) (string, error) {
 	q := fmt.Sprintf("%s.", cfg.domain)
+
+	if cfg.hostedZoneID != "" {
+		out, err := cfg.r53.GetHostedZone(&route53.GetHostedZoneInput{
+			Id: aws.String(cfg.hostedZoneID),
+		})
+		if err != nil {
+			return "", err
+		}
+
+		hz := out.HostedZone
+		if !equalNames(*hz.Name, q) {
+			return "", fmt.Errorf("Hosted zone %s is for '%s', not '%s'", cfg.hostedZoneID, *hz.Name, cfg.domain)
+		}
+
+		if *hz.Config.PrivateZone && !cfg.private {
+			return "", fmt.Errorf("Hosted zone %s is a private zone, use --private to compare it", cfg.hostedZoneID)
+		}
+
+		if !*hz.Config.PrivateZone && cfg.private {
+			return "", fmt.Errorf("Hosted zone %s is not a private zone", cfg.hostedZoneID)
+		}
+
+		return *hz.Id, nil
+	}
+
 	out, err := cfg.r53.ListHostedZonesByName(&route53.ListHostedZonesByNameInput{
 		DNSName: aws.String(q),
 	})
-	checkErr(err)
+	if err != nil {
+		return "", err
+	}
 
+	candidates := make([]*route53.HostedZone, 0)
 	for _, hz := range out.HostedZones {
-		if *hz.Config.PrivateZone == false && *hz.Name == q {
-			hzid = *hz.Id
-			break
+		if *hz.Config.PrivateZone == cfg.private && *hz.Name == q {
+			candidates = append(candidates, hz)
 		}
 	}
 
-	if hzid == "" {
-		checkErr(fmt.Errorf("Unable to find domain '%s' in route53", cfg.domain))
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("Unable to find domain '%s' in route53", cfg.domain)
+	case 1:
+		return *candidates[0].Id, nil
 	}
 
-	// verify domain exists in cloudflare
-	zoneID, err := cfg.api.ZoneIDByName(cfg.domain)
-	checkErr(err)
+	if !isTerminal(os.Stdin) {
+		ids := make([]string, 0, len(candidates))
+		for _, hz := range candidates {
+			ids = append(ids, *hz.Id)
+		}
+		return "", fmt.Errorf("Multiple route53 hosted zones named '%s' (%s), use --hosted-zone-id to pick one", cfg.domain, strings.Join(ids, ", "))
+	}
 
-	// Fetch route53 record set
-	err = cfg.r53.ListResourceRecordSetsPages(&route53.ListResourceRecordSetsInput{
-		HostedZoneId: aws.String(hzid),
-	}, func(page *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
-		for _, r := range page.ResourceRecordSets {
-			// determine if record is a genuine A record or an alias record
-			cfg.awsRecordSet = append(cfg.awsRecordSet, record{
-				Name: *r.Name,
-				Type: *r.Type,
-			})
+	fmt.Printf("Multiple route53 hosted zones named '%s':\n", cfg.domain)
+	for i, hz := range candidates {
+		comment := ""
+		if hz.Config.Comment != nil {
+			comment = *hz.Config.Comment
 		}
-		return true
-	})
-	checkErr(err)
-
-	// Fetch cloudflare record set
-	records, err := cfg.api.DNSRecords(zoneID, cloudflare.DNSRecord{})
-	checkErr(err)
-
-	for _, r := range records {
-		cfg.cfRecordSet = append(cfg.cfRecordSet, record{
-			Name:  r.Name,
-			Value: []string{r.Content},
-			Type:  r.Type,
-			TTL:   r.TTL,
-		})
+		fmt.Printf("  %d) %s (%d records) %s\n", i+1, *hz.Id, aws.Int64Value(hz.ResourceRecordSetCount), comment)
+	}
+
+	for {
+		fmt.Printf("Select a hosted zone [1-%d]: ", len(candidates))
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		n, err := strconv.Atoi(strings.TrimSpace(line))
+		if err == nil && n >= 1 && n <= len(candidates) {
+			return *candidates[n-1].Id, nil
+		}
+	}
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// runZones calls fn for every zone selected by the configuration, returning
+// the configuration and zones for any reporting afterwards.
+func runZones(fn func(*config, *zone) error) (*config, []*zone) {
+	// several profiles run in turn, each as if run on its own
+	profiles := strings.Split(viper.GetString("profile"), ",")
+
+	var cfg *config
+	all := make([]*zone, 0)
+	for i, name := range profiles {
+		if len(profiles) > 1 {
+			restoreOverrides()
+			viper.Set("profile", name)
+
+			// the lookups cached for all zones belong to the last account
+			trailChanges = nil
+			cloudfrontDistributions = nil
+			placeholderValues = make(map[string]string)
+		}
+
+		var err error
+		cfg, err = assembleConfig()
+		checkErr(err)
+
+		if i == 0 {
+			status.start(viper.GetDuration("heartbeat"), viper.GetString("status-file"))
+		}
+
+		if cfg.private {
+			fmt.Fprintln(os.Stderr, "WARNING: private hosted zones are not reachable through Cloudflare's proxy, proxied settings do not apply")
+		}
+
+		status.setPhase("", "finding zones")
+		zones, err := findZones(cfg)
+		checkErr(err)
+		status.setZones(len(all) + len(zones))
+
+		for _, z := range zones {
+			checkErr(renewCredentials(cfg))
+			checkErr(fn(cfg, z))
+			status.zoneDone()
+		}
+		all = append(all, zones...)
+	}
+
+	status.setPhase("", "done")
+
+	return cfg, all
+}
+
+func doCompare(cmd *cobra.Command, args []string) {
+	cfg, zones := runZones(compareZone)
+
+	if cfg.output == "git-comment" {
+		body := renderComment(zones)
+		fmt.Print(body)
+		checkErr(postComment(body))
+	}
+
+	if cfg.output == "json" {
+		report, err := renderJSON(zones)
+		checkErr(err)
+		fmt.Println(string(report))
+	}
+
+	checkErr(sendReports(zones))
+
+	// the reports go out first, strict mode then fails the run
+	for _, z := range zones {
+		checkErr(strictCheck(z, nil))
+	}
+}
+
+// apex returns the name of the cloudflare zone, a subdomain being split out
+// lives in a cloudflare zone of its own.
+func (z *zone) apex() string {
+	if z.subdomain != "" {
+		return z.subdomain
+	}
+	return z.name
+}
+
+// loadZone fetches the records of a zone from both providers, falling back
+// to cached cloudflare records when allowed.
+func loadZone(cfg *config, z *zone) error {
+	cfName := z.apex()
+
+	if err := fetchRoute53(cfg, z); err != nil {
+		return err
+	}
+
+	if err := fetchCloudflare(cfg, z, cfName); err != nil {
+		if !cfg.allowStale {
+			return err
+		}
+
+		// fall back to the last known cloudflare state so the compare can
+		// still complete while cloudflare is unavailable
+		cached, cerr := loadCache(cfg.cacheDir, cfName)
+		if cerr != nil {
+			return fmt.Errorf("%s (no usable cache: %s)", err, cerr)
+		}
+
+		fmt.Fprintf(os.Stderr, "WARNING: cloudflare unavailable for %s: %s\n", cfName, err)
+		z.zoneID = cached.ZoneID
+		z.cfRecordSet = cached.Records
+		z.stale = cached.Fetched
+	}
+
+	return nil
+}
+
+// printZone prints the zone header and the alias conversions made while
+// reading it from route53.
+func printZone(z *zone) {
+	fmt.Printf("Zone: %s\n", z.apex())
+	if !z.stale.IsZero() {
+		fmt.Printf("STALE: cloudflare records are from the cache of %s\n", z.stale.Format(time.RFC3339))
+	}
+
+	if len(z.conversions) > 0 {
+		fmt.Println("Converted alias records:")
+		for _, c := range z.conversions {
+			fmt.Printf("  %s\n", c)
+		}
+	}
+
+	if len(z.transformed) > 0 {
+		fmt.Println("Transformed records:")
+		for _, t := range z.transformed {
+			fmt.Printf("  %s\n", t)
+		}
+	}
+
+	if len(z.chains) > 0 {
+		fmt.Println("CNAME chains:")
+		for _, c := range z.chains {
+			fmt.Printf("  %s\n", c)
+		}
+	}
+
+	if len(z.problems) > 0 {
+		fmt.Println("Record problems:")
+		for _, p := range z.problems {
+			fmt.Printf("  %s\n", p)
+		}
+	}
+
+	proxied := 0
+	for _, r := range z.cfRecordSet {
+		if r.Proxied {
+			proxied++
+		}
+	}
+	if proxied > 0 {
+		fmt.Printf("Proxied: %d cloudflare records answer with cloudflare addresses, they are compared by their origin\n", proxied)
+	}
+
+	if len(z.routed) > 0 {
+		fmt.Println("Routing policy records:")
+		for _, r := range z.routed {
+			fmt.Printf("  %s\n", r)
+		}
+	}
+
+	if len(z.manual) > 0 {
+		fmt.Println("Needs manual action:")
+		for _, m := range z.manual {
+			fmt.Printf("  %s\n", m)
+		}
+	}
+
+	if len(z.settling) > 0 {
+		fmt.Println("Settling, changed too recently to compare:")
+		for _, r := range z.settling {
+			fmt.Printf("  %s\n", r)
+		}
+	}
+
+	if len(z.timeline) > 0 {
+		fmt.Println("Recent changes:")
+		for _, t := range z.timeline {
+			fmt.Printf("  %s\n", t)
+		}
+	}
+
+	if len(z.subzones) > 0 {
+		fmt.Println("Delegated subdomains:")
+		for _, r := range z.subzones {
+			fmt.Printf("  %s NS %s\n", r.Name, strings.Join(r.Value, ", "))
+		}
+	}
+}
+
+func compareZone(cfg *config, z *zone) error {
+	if err := loadZone(cfg, z); err != nil {
+		return err
+	}
+
+	z.diffs, z.matching = diffZone(z, cfg.ignoreTTL)
+	if err := settleZone(cfg, z, viper.GetDuration("settle")); err != nil {
+		return err
+	}
+	validateZone(z)
+
+	if viper.GetBool("audit-log") && z.stale.IsZero() {
+		if err := auditZone(cfg, z); err != nil {
+			return err
+		}
+	}
+
+	if viper.GetBool("cloudtrail") {
+		if err := trailZone(cfg, z); err != nil {
+			return err
+		}
+	}
+
+	// other outputs are rendered once all zones are compared
+	if cfg.output != "text" {
+		return nil
+	}
+
+	printZone(z)
+	printDiff(z.diffs, z.matching)
+
+	if cfg.live {
+		lines, ns, err := compareLive(cfg, z)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Live answers from %s: %d differences\n", ns, len(lines))
+		for _, l := range lines {
+			fmt.Printf("  %s\n", l)
+		}
+	}
+
+	if z.subdomain != "" && z.stale.IsZero() {
+		return printDelegation(cfg, z)
+	}
+
+	return nil
+}
+
+// equalNames compares two domain names ignoring case and trailing dots.
+func equalNames(a, b string) bool {
+	return normalizeName(a) == normalizeName(b)
+}
+
+// unescapeName decodes the octal escapes route53 uses for characters
+// outside letters, digits, hyphens and underscores, such as \052 for the
+// wildcard label.
+func unescapeName(name string) string {
+	if !strings.Contains(name, `\`) {
+		return name
 	}
 
-	spew.Dump(cfg.cfRecordSet)
+	b := &strings.Builder{}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && isByteEscape(name[i+1:], 8) {
+			n, _ := strconv.ParseUint(name[i+1:i+4], 8, 8)
+			b.WriteByte(byte(n))
+			i += 3
+			continue
+		}
+		b.WriteByte(name[i])
+	}
+
+	return b.String()
+}
+
+// normalizeName returns the form names are compared in: unescaped,
+// punycode, lower case and without the trailing dot.
+func normalizeName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(toASCIIName(unescapeName(name)), "."))
+}
+
+// equalNameSets reports whether both lists hold the same domain names in
+// any order.
+func equalNameSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			if equalNames(x, y) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inZone reports whether name is equal to or below the zone apex.
+func inZone(name, apex string) bool {
+	name = normalizeName(name)
+	apex = normalizeName(apex)
+
+	return name == apex || strings.HasSuffix(name, "."+apex)
 }
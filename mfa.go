@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().String("aws-mfa-serial", "", "Serial number or ARN of the MFA device the AWS account requires for API access")
+	viper.BindPFlag("aws-mfa-serial", rootCmd.PersistentFlags().Lookup("aws-mfa-serial"))
+
+	rootCmd.PersistentFlags().String("aws-mfa-code", "", "Current MFA code, prompted for on the terminal when needed and not given")
+	viper.BindPFlag("aws-mfa-code", rootCmd.PersistentFlags().Lookup("aws-mfa-code"))
+}
+
+// mfaDuration is how long the session of an MFA sign in lasts.
+const mfaDuration = 12 * 60 * 60
+
+// mfaCode returns the MFA code given with --aws-mfa-code, or prompts for
+// it. Profiles assuming a role with mfa_serial ask for it the same way.
+func mfaCode() (string, error) {
+	if code := viper.GetString("aws-mfa-code"); code != "" {
+		return code, nil
+	}
+
+	if !isTerminal(os.Stdin) {
+		return "", errors.New("An MFA code is required, use --aws-mfa-code when not running interactively")
+	}
+
+	return stscreds.StdinTokenProvider()
+}
+
+// mfaProvider serves the temporary credentials of an MFA sign in, signing
+// in again when they expire. A code given with --aws-mfa-code is only good
+// for the first sign in, later ones prompt for a new code.
+type mfaProvider struct {
+	credentials.Expiry
+
+	sess     *session.Session
+	serial   string
+	signedIn bool
+}
+
+func (p *mfaProvider) Retrieve() (credentials.Value, error) {
+	code, err := mfaCode()
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	if p.signedIn && code == viper.GetString("aws-mfa-code") {
+		return credentials.Value{}, errors.New("The MFA session expired, run again with a new --aws-mfa-code")
+	}
+
+	out, err := sts.New(p.sess).GetSessionToken(&sts.GetSessionTokenInput{
+		SerialNumber:    aws.String(p.serial),
+		TokenCode:       aws.String(code),
+		DurationSeconds: aws.Int64(mfaDuration),
+	})
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("Unable to sign in with MFA device %s: %s", p.serial, err)
+	}
+
+	p.signedIn = true
+	c := out.Credentials
+	p.SetExpiration(*c.Expiration, credentialMargin)
+
+	return credentials.Value{
+		AccessKeyID:     *c.AccessKeyId,
+		SecretAccessKey: *c.SecretAccessKey,
+		SessionToken:    *c.SessionToken,
+		ProviderName:    "mfa",
+	}, nil
+}
+
+// mfaSession signs in with the MFA device of --aws-mfa-serial, returning a
+// session using the temporary credentials obtained. Without a serial the
+// session is returned as is.
+func mfaSession(sess *session.Session) (*session.Session, error) {
+	serial := viper.GetString("aws-mfa-serial")
+	if serial == "" {
+		return sess, nil
+	}
+
+	creds := credentials.NewCredentials(&mfaProvider{sess: sess, serial: serial})
+	if _, err := creds.Get(); err != nil {
+		return nil, err
+	}
+
+	return sess.Copy(&aws.Config{Credentials: creds}), nil
+}
+
+// credentialMargin is how long before they expire temporary AWS
+// credentials are renewed.
+const credentialMargin = 10 * time.Minute
+
+// renewCredentials renews AWS credentials about to expire before the next
+// zone, rather than failing halfway through it. Static keys don't expire,
+// assumed roles, instance roles and MFA sessions are renewed by their
+// provider. Credentials that can't be renewed fail the run while it is
+// between zones, which migrate resumes from.
+func renewCredentials(cfg *config) error {
+	creds := cfg.session.Config.Credentials
+	expires, err := creds.ExpiresAt()
+	if err != nil || time.Until(expires) > credentialMargin {
+		return nil
+	}
+
+	creds.Expire()
+	if _, err := creds.Get(); err != nil {
+		return fmt.Errorf("AWS credentials expire at %s and could not be renewed: %s", expires.Format(time.RFC3339), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	migrateCmd.Flags().Bool("dry-run", false, "Print the changes that would be made without applying them")
+	viper.BindPFlag("dry-run", migrateCmd.Flags().Lookup("dry-run"))
+
+	migrateCmd.Flags().Bool("confirm-each-zone", false, "Ask for confirmation before applying the changes to each zone")
+	viper.BindPFlag("confirm-each-zone", migrateCmd.Flags().Lookup("confirm-each-zone"))
+
+	migrateCmd.Flags().Duration("max-duration", 0, "Pause cleanly once the run has taken this long (e.g. 30m), exiting with status 3, rerunning migrate resumes")
+	viper.BindPFlag("max-duration", migrateCmd.Flags().Lookup("max-duration"))
+
+	migrateCmd.Flags().String("plan-json", "", "Write the plan of every zone to this file as JSON, see the schema command")
+	viper.BindPFlag("plan-json", migrateCmd.Flags().Lookup("plan-json"))
+
+	rootCmd.AddCommand(migrateCmd)
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Create the Route53 records that are missing or different in Cloudflare",
+	Run:   doMigrate,
+}
+
+// change is a single cloudflare write needed to bring a zone in line with
+// route53.
+type change struct {
+	Action string
+	Record cloudflare.DNSRecord
+
+	// Value is the record value in presentation format, as shown in plans
+	Value string
+}
+
+func (c change) String() string {
+	s := fmt.Sprintf("%s %s %s %d %s", c.Action, c.Record.Name, c.Record.Type, c.Record.TTL, c.Value)
+	if c.Record.Proxied {
+		s += " (proxied)"
+	}
+	return s
+}
+
+// plans collects the plan of each zone for --plan-json.
+var plans []jsonPlanZone
+
+// deadline is when a time-boxed run pauses, zero when it runs to the end.
+var deadline time.Time
+
+// pausedExit is the exit status of a run paused at its time limit, so
+// scripts can tell it from one that finished or failed.
+const pausedExit = 3
+
+// pausedZones counts the zones a paused run left for the next.
+var pausedZones int
+
+// paused reports whether a time-boxed run has reached its time limit.
+func paused() bool {
+	return !deadline.IsZero() && time.Now().After(deadline)
+}
+
+func doMigrate(cmd *cobra.Command, args []string) {
+	if d := viper.GetDuration("max-duration"); d > 0 {
+		deadline = time.Now().Add(d)
+	}
+
+	_, zones := runZones(migrateZone)
+
+	if file := viper.GetString("plan-json"); file != "" {
+		b, err := renderPlanJSON(plans)
+		checkErr(err)
+		checkErr(ioutil.WriteFile(file, b, 0644))
+	}
+
+	// the plan is worked out from cloudflare's current records, so a rerun
+	// picks up where this one stopped
+	if pausedZones > 0 {
+		fmt.Fprintf(os.Stderr, "Paused at the time limit of %s with %d of %d zones left, run migrate again to resume\n", viper.GetDuration("max-duration"), pausedZones, len(zones))
+		status.setPhase("", "paused")
+		reportUsage(nil)
+		os.Exit(pausedExit)
+	}
+}
+
+// planZone works out the cloudflare writes for a zone. Every route53 value
+// missing from cloudflare is created and values present with a different
+// TTL or proxy setting are updated, records only in cloudflare are left
+// alone. Values that can't be converted are returned as skipped.
+func planZone(z *zone) ([]change, []string) {
+	existing := make(map[string][]record)
+	for _, r := range z.cfRecordSet {
+		k := recordKey(r.Name, r.Type)
+		existing[k] = append(existing[k], r)
+	}
+
+	// a grouped set whose records disagree on the TTL has mixedTTL, so
+	// every value keeps the TTL of the record it came from
+	ttls := make(map[string]int)
+	for _, r := range z.awsRecordSet {
+		for _, v := range r.Value {
+			ttls[recordKey(r.Name, r.Type)+" "+v] = r.TTL
+		}
+	}
+
+	sets, keys := groupRecords(z.awsRecordSet)
+
+	plan := make([]change, 0)
+	skipped := make([]string, 0)
+	for _, k := range keys {
+		set := sets[k]
+		for _, v := range set.Value {
+			ttl := set.TTL
+			if ttl == mixedTTL {
+				ttl = ttls[k+" "+v]
+			}
+
+			rr, err := toCloudflare(set.Name, set.Type, ttl, v)
+			if err != nil {
+				skipped = append(skipped, fmt.Sprintf("%s %s: %s", set.Name, set.Type, err))
+				continue
+			}
+			rr.Proxied = set.Proxied
+
+			var match *record
+			for i, r := range existing[k] {
+				if equalValues(z.canonical, set.Type, r.Value, []string{v}) {
+					match = &existing[k][i]
+					break
+				}
+			}
+
+			switch {
+			case match == nil:
+				plan = append(plan, change{Action: "create", Record: rr, Value: v})
+			case match.TTL != ttl || match.Proxied != set.Proxied:
+				rr.ID = match.ID
+				plan = append(plan, change{Action: "update", Record: rr, Value: v})
+			}
+		}
+	}
+
+	return plan, skipped
+}
+
+func migrateZone(cfg *config, z *zone) error {
+	if paused() {
+		fmt.Printf("Skipping %s, time limit reached\n", z.apex())
+		pausedZones++
+		return nil
+	}
+
+	if err := loadZone(cfg, z); err != nil {
+		return err
+	}
+	validateZone(z)
+
+	printZone(z)
+
+	if !z.stale.IsZero() {
+		if err := strictCheck(z, nil); err != nil {
+			return err
+		}
+
+		fmt.Printf("Cloudflare data for %s is stale, deferring migration\n", z.apex())
+		return nil
+	}
+
+	plan, skipped := planZone(z)
+	for _, s := range skipped {
+		fmt.Printf("WARNING: skipping %s\n", s)
+	}
+
+	if errs := checkPlan(z, plan); len(errs) > 0 {
+		fmt.Println("Invalid plan:")
+		for _, e := range errs {
+			fmt.Printf("  %s\n", e)
+		}
+		return fmt.Errorf("The plan for %s breaks DNS rules, fix the records listed above", z.apex())
+	}
+
+	balancers, err := planBalancers(cfg, z)
+	if err != nil {
+		return err
+	}
+	for _, w := range z.warnings {
+		fmt.Printf("WARNING: %s\n", w)
+	}
+
+	if err := strictCheck(z, skipped); err != nil {
+		return err
+	}
+
+	plans = append(plans, newJSONPlanZone(z, plan, balancers, skipped))
+
+	if len(plan) == 0 && len(balancers) == 0 {
+		fmt.Println("Nothing to migrate")
+		return nil
+	}
+
+	fmt.Println("Plan:")
+	for _, c := range plan {
+		fmt.Printf("  %s\n", c)
+	}
+	for _, b := range balancers {
+		fmt.Printf("  create load balancer %s\n", b)
+	}
+
+	if viper.GetBool("dry-run") {
+		return nil
+	}
+
+	if viper.GetBool("confirm-each-zone") {
+		ok, err := confirmZone(z, plan, balancers)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			fmt.Printf("Skipping %s\n", z.apex())
+			return nil
+		}
+	}
+
+	status.setPhase(z.apex(), "applying changes")
+
+	failed := make([]string, 0)
+	for _, c := range plan {
+		if paused() {
+			break
+		}
+
+		var err error
+		switch c.Action {
+		case "create":
+			_, err = cfg.api.CreateDNSRecord(z.zoneID, c.Record)
+		case "update":
+			err = cfg.api.UpdateDNSRecord(z.zoneID, c.Record.ID, c.Record)
+		}
+
+		if err != nil {
+			fmt.Printf("  failed: %s: %s\n", c, err)
+			failed = append(failed, c.Record.Name)
+			continue
+		}
+
+		fmt.Printf("  done: %s\n", c)
+	}
+
+	for _, b := range balancers {
+		if paused() {
+			break
+		}
+
+		if err := createBalancer(cfg, z, b); err != nil {
+			fmt.Printf("  failed: load balancer %s: %s\n", b.Name, err)
+			failed = append(failed, b.Name)
+			continue
+		}
+
+		fmt.Printf("  done: load balancer %s\n", b.Name)
+	}
+
+	if paused() {
+		fmt.Printf("  time limit reached, %s not finished\n", z.apex())
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d changes to %s failed: %s", len(failed), len(plan)+len(balancers), z.apex(), strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// confirmZone asks whether the plan for a zone should be applied. Quitting
+// ends the whole run.
+func confirmZone(z *zone, plan []change, balancers []balancedSet) (bool, error) {
+	if !isTerminal(os.Stdin) {
+		return false, errors.New("Confirming each zone requires an interactive terminal")
+	}
+
+	counts := make(map[string]int)
+	for _, c := range plan {
+		counts[c.Action]++
+	}
+
+	for {
+		fmt.Printf("Apply %d creates, %d updates and %d load balancers to %s? [y/n/q]: ", counts["create"], counts["update"], len(balancers), z.apex())
+		line, err := stdin.ReadString('\n')
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(line)) {
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		case "q", "quit":
+			return false, errors.New("Migration stopped by user")
+		}
+	}
+}
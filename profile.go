@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().String("profile", "", "Use the settings of this profile of the config file, such as another Cloudflare or AWS account. Several comma separated profiles run in turn, each with its own credentials and the zones of its domain or group")
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+
+	configSetting("profiles", false)
+}
+
+// credentialSources are the settings that each supply the same credential.
+// A profile setting one of them clears the others, or a key of the rest of
+// the config or the environment would win over a key file of the profile.
+// An AWS profile supplies both AWS keys.
+var credentialSources = [][]string{
+	{"cfkey", "cfkey-file", "cf-key-from", "cftoken", "cftoken-file", "cf-token-from"},
+	{"awskey", "awskey-file", "aws-profile"},
+	{"awssecret", "awssecret-file", "aws-profile"},
+}
+
+// loadProfile applies the settings of a named profile from the profiles
+// section of the config file. They override the rest of the config file
+// and the environment, while group defaults and command line flags still
+// win. A profile can hold any setting, credentials and groups included.
+//
+//	profiles:
+//	  prod-us:
+//	    cfemail: dns@example.com
+//	    cfkey-file: /run/secrets/cf-prod-us
+//	    aws-profile: prod-us
+//	    proxied: ["www.*"]
+//	    groups:
+//	      web:
+//	        domains: [example.com, example.net]
+func loadProfile(name string) error {
+	key := "profiles." + name
+	if !viper.IsSet(key) {
+		return fmt.Errorf("Unknown profile '%s'", name)
+	}
+
+	settings := viper.GetStringMap(key)
+	for k, v := range settings {
+		switch {
+		case k == "profile" || k == "profiles":
+			return errors.New("Profiles can not select other profiles")
+		case !flagChanged(k):
+			override(k, v)
+		}
+	}
+
+	for _, sources := range credentialSources {
+		set := false
+		for _, k := range sources {
+			if _, ok := settings[k]; ok {
+				set = true
+			}
+		}
+		if !set {
+			continue
+		}
+
+		for _, k := range sources {
+			if _, ok := settings[k]; !ok && !flagChanged(k) {
+				override(k, "")
+			}
+		}
+	}
+
+	return nil
+}
+
+// overridden holds the values of the settings a profile or zone group
+// replaced, from before the first replacement.
+var overridden = make(map[string]interface{})
+
+// override replaces the value of a setting, remembering the value before.
+func override(key string, value interface{}) {
+	if _, ok := overridden[key]; !ok {
+		overridden[key] = viper.Get(key)
+	}
+	viper.Set(key, value)
+}
+
+// restoreOverrides puts back the settings replaced by a profile and its
+// zone group, so the next profile of a run starts from the same settings.
+func restoreOverrides() {
+	for k, v := range overridden {
+		viper.Set(k, v)
+	}
+	overridden = make(map[string]interface{})
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// proxyRules select the records created proxied in cloudflare, all others
+// are DNS only. Rules are names relative to the zone apex, "@" for the apex
+// itself, and may be glob patterns such as "*.app".
+type proxyRules []string
+
+// parseProxyRules checks the patterns of proxy rules.
+func parseProxyRules(rules []string) (proxyRules, error) {
+	p := make(proxyRules, 0, len(rules))
+	for _, r := range rules {
+		r = strings.ToLower(strings.TrimSuffix(r, "."))
+		if _, err := path.Match(r, ""); err != nil {
+			return nil, fmt.Errorf("Invalid proxied rule '%s'", r)
+		}
+		p = append(p, r)
+	}
+
+	return p, nil
+}
+
+// relativeName returns a name relative to the apex of its zone.
+func relativeName(name, apex string) string {
+	name, apex = normalizeName(name), normalizeName(apex)
+	if name == apex {
+		return "@"
+	}
+
+	return strings.TrimSuffix(name, "."+apex)
+}
+
+// proxiable reports whether cloudflare can proxy records of a type.
+func proxiable(typ string) bool {
+	return typ == "A" || typ == "AAAA" || typ == "CNAME"
+}
+
+// match reports whether a record is to be proxied.
+func (p proxyRules) match(apex, name, typ string) bool {
+	if !proxiable(typ) {
+		return false
+	}
+
+	rel := relativeName(name, apex)
+	for _, r := range p {
+		if ok, _ := path.Match(r, rel); ok {
+			return true
+		}
+	}
+
+	return false
+}
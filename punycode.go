@@ -0,0 +1,132 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// punycode parameters of RFC 3492
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punyAdapt is the bias adaptation function of RFC 3492.
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+// punyDigit returns the character of a base 36 digit.
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// punyThreshold clamps the threshold of digit k to the bias.
+func punyThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	}
+	return k - bias
+}
+
+// encodePunycode encodes a label as punycode, without the xn-- prefix.
+func encodePunycode(label string) string {
+	runes := []rune(label)
+
+	b := &strings.Builder{}
+	for _, r := range runes {
+		if r < punyInitialN {
+			b.WriteRune(r)
+		}
+	}
+
+	basic := b.Len()
+	handled := basic
+	if basic > 0 {
+		b.WriteByte('-')
+	}
+
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for handled < len(runes) {
+		// the smallest code point not handled yet
+		m := int(utf8.MaxRune)
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+
+		delta += (m - n) * (handled + 1)
+		n = m
+
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				b.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			b.WriteByte(punyDigit(q))
+
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+
+		delta++
+		n++
+	}
+
+	return b.String()
+}
+
+// toASCIIName converts the Unicode labels of an internationalized name to
+// their xn-- punycode form. Labels are only lower cased, not fully mapped
+// as IDNA does, which covers the names seen in DNS zones.
+func toASCIIName(name string) string {
+	labels := strings.Split(name, ".")
+	for i, l := range labels {
+		for _, r := range l {
+			if r >= utf8.RuneSelf {
+				labels[i] = "xn--" + encodePunycode(strings.ToLower(l))
+				break
+			}
+		}
+	}
+
+	return strings.Join(labels, ".")
+}
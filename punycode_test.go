@@ -0,0 +1,59 @@
+package main
+
+import "testing"
+
+// punycodeSamples are the samples of RFC 3492 section 7.1, with the digits
+// the RFC upper cases as mixed case annotations in lower case.
+var punycodeSamples = []struct {
+	name    string
+	unicode string
+	ascii   string
+}{
+	{"Arabic (Egyptian)", "ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+	{"Chinese (simplified)", "他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+	{"Chinese (traditional)", "他們爲什麽不說中文", "ihqwctvzc91f659drss3x8bo0yb"},
+	{"Czech", "Pročprostěnemluvíčesky", "Proprostnemluvesky-uyb24dma41a"},
+	{"Hebrew", "למההםפשוטלאמדבריםעברית", "4dbcagdahymbxekheh6e0a7fei0b"},
+	{"Hindi (Devanagari)", "यहलोगहिन्दीक्योंनहींबोलसकतेहैं", "i1baa7eci9glrd9b2ae1bj0hfcgg6iyaf8o0a1dig0cd"},
+	{"Japanese (kanji and hiragana)", "なぜみんな日本語を話してくれないのか", "n8jok5ay5dzabd5bym9f0cm5685rrjetr6pdxa"},
+	{"Korean (Hangul syllables)", "세계의모든사람들이한국어를이해한다면얼마나좋을까", "989aomsvi5e83db1d2a355cv1e0vak1dwrv93d5xbh15a0dt30a5jpsd879ccm6fea98c"},
+	{"Russian (Cyrillic)", "почемужеонинеговорятпорусски", "b1abfaaepdrnnbgefbadotcwatmq2g4l"},
+	{"Spanish", "PorquénopuedensimplementehablarenEspañol", "PorqunopuedensimplementehablarenEspaol-fmd56a"},
+	{"Vietnamese", "TạisaohọkhôngthểchỉnóitiếngViệt", "TisaohkhngthchnitingVit-kjcr8268qyxafd2f1b9g"},
+	{"3<nen>B<gumi><kinpachi><sensei>", "3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+	{"<amuro><namie>-with-SUPER-MONKEYS", "安室奈美恵-with-SUPER-MONKEYS", "-with-SUPER-MONKEYS-pc58ag80a8qai00g7n9n"},
+	{"Hello-Another-Way-<sorezore><no><basho>", "Hello-Another-Way-それぞれの場所", "Hello-Another-Way--fc4qua05auwb3674vfr0b"},
+	{"<hitotsu><yane><no><shita>2", "ひとつ屋根の下2", "2-u9tlzr9756bt3uc0v"},
+	{"Maji<de>Koi<suru>5<byou><mae>", "MajiでKoiする5秒前", "MajiKoi5-783gue6qz075azm5e"},
+	{"<pafii>de<runba>", "パフィーdeルンバ", "de-jg4avhby1noc0d"},
+	{"<sono><supiido><de>", "そのスピードで", "d9juau41awczczp"},
+	{"-> $1.00 <-", "-> $1.00 <-", "-> $1.00 <--"},
+}
+
+func TestEncodePunycode(t *testing.T) {
+	for _, s := range punycodeSamples {
+		if got := encodePunycode(s.unicode); got != s.ascii {
+			t.Errorf("%s: got %q, want %q", s.name, got, s.ascii)
+		}
+	}
+}
+
+func TestToASCIIName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"bücher.example.com", "xn--bcher-kva.example.com"},
+		{"Bücher.example.com", "xn--bcher-kva.example.com"},
+		{"münchen.de.", "xn--mnchen-3ya.de."},
+		{"www.例え.jp", "www.xn--r8jz45g.jp"},
+		{"_sip._tcp.example.com", "_sip._tcp.example.com"},
+		{"xn--bcher-kva.example.com", "xn--bcher-kva.example.com"},
+	}
+
+	for _, tt := range tests {
+		if got := toASCIIName(tt.name); got != tt.want {
+			t.Errorf("toASCIIName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(referencesCmd)
+}
+
+var referencesCmd = &cobra.Command{
+	Use:   "references",
+	Short: "Find CNAME, MX and SRV targets pointing into other Route53 zones",
+	Long: `Lists the records of the selected zones whose targets are names in other
+hosted zones of the account, and groups the zones that reference each other
+so they can be migrated together.`,
+	Run: doReferences,
+}
+
+// reference is a record pointing at a name in another managed zone.
+type reference struct {
+	from   string
+	name   string
+	typ    string
+	target string
+	to     string
+}
+
+// recordTarget returns the host name a record points at, if any.
+func recordTarget(typ, value string) string {
+	fields := strings.Fields(value)
+
+	var target string
+	switch {
+	case typ == "CNAME" && len(fields) == 1:
+		target = fields[0]
+	case typ == "MX" && len(fields) == 2:
+		target = fields[1]
+	case typ == "SRV" && len(fields) == 4:
+		target = fields[3]
+	}
+
+	return strings.ToLower(strings.TrimSuffix(target, "."))
+}
+
+// owningZone returns the most specific of the zones containing name.
+func owningZone(name string, zones []string) string {
+	owner := ""
+	for _, z := range zones {
+		if inZone(name, z) && len(z) > len(owner) {
+			owner = z
+		}
+	}
+
+	return owner
+}
+
+// managedZones lists the names of every hosted zone in the account of the
+// same visibility as the zones being compared.
+func managedZones(cfg *config) ([]string, error) {
+	names := make([]string, 0)
+	err := cfg.r53.ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(page *route53.ListHostedZonesOutput, lastPage bool) bool {
+		for _, hz := range page.HostedZones {
+			if *hz.Config.PrivateZone == cfg.private {
+				names = append(names, strings.ToLower(strings.TrimSuffix(*hz.Name, ".")))
+			}
+		}
+		return true
+	})
+
+	return names, err
+}
+
+// findReferences returns the references from the records of z into other
+// managed zones.
+func findReferences(z *zone, managed []string) []reference {
+	refs := make([]reference, 0)
+	from := strings.ToLower(z.apex())
+
+	for _, r := range z.awsRecordSet {
+		for _, v := range r.Value {
+			target := recordTarget(r.Type, v)
+			if target == "" {
+				continue
+			}
+
+			to := owningZone(target, managed)
+			if to == "" || to == from {
+				continue
+			}
+
+			refs = append(refs, reference{
+				from:   from,
+				name:   strings.TrimSuffix(r.Name, "."),
+				typ:    r.Type,
+				target: target,
+				to:     to,
+			})
+		}
+	}
+
+	return refs
+}
+
+// groupZones joins zones referencing each other, directly or through other
+// zones, into groups that should move together.
+func groupZones(refs []reference) [][]string {
+	parent := make(map[string]string)
+
+	var find func(string) string
+	find = func(z string) string {
+		if _, ok := parent[z]; !ok {
+			parent[z] = z
+		}
+		if parent[z] != z {
+			parent[z] = find(parent[z])
+		}
+		return parent[z]
+	}
+
+	for _, r := range refs {
+		parent[find(r.from)] = find(r.to)
+	}
+
+	members := make(map[string][]string)
+	for z := range parent {
+		root := find(z)
+		members[root] = append(members[root], z)
+	}
+
+	groups := make([][]string, 0, len(members))
+	for _, m := range members {
+		sort.Strings(m)
+		groups = append(groups, m)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+
+	return groups
+}
+
+func doReferences(cmd *cobra.Command, args []string) {
+	cfg, err := assembleConfig()
+	checkErr(err)
+
+	zones, err := findZones(cfg)
+	checkErr(err)
+
+	managed, err := managedZones(cfg)
+	checkErr(err)
+
+	selected := make(map[string]bool)
+	refs := make([]reference, 0)
+	for _, z := range zones {
+		selected[strings.ToLower(z.apex())] = true
+		checkErr(fetchRoute53(cfg, z))
+		refs = append(refs, findReferences(z, managed)...)
+	}
+
+	if len(refs) == 0 {
+		fmt.Println("No references between zones")
+		return
+	}
+
+	fmt.Println("References between zones:")
+	for _, r := range refs {
+		fmt.Printf("  %s %s -> %s (zone %s)\n", r.name, r.typ, r.target, r.to)
+	}
+
+	fmt.Println("Zones that should move together:")
+	for _, g := range groupZones(refs) {
+		missing := make([]string, 0)
+		for _, z := range g {
+			if !selected[z] {
+				missing = append(missing, z)
+			}
+		}
+
+		fmt.Printf("  %s\n", strings.Join(g, ", "))
+		if len(missing) > 0 {
+			fmt.Printf("    not selected: %s, references into these zones break if their records change during the migration\n", strings.Join(missing, ", "))
+		}
+	}
+}
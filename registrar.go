@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// rdapBootstrap redirects RDAP queries to the server of the registry
+// responsible for the domain.
+const rdapBootstrap = "https://rdap.org/domain/"
+
+// registrarSteps are the delegation change instructions of well known
+// registrars, matched by a part of the registrar's name.
+var registrarSteps = []struct {
+	match string
+	steps string
+}{
+	{"amazon", "The domain is registered with Route53 Domains. Switch the name servers with switch-ns, --dry-run shows the change first:\n\n```\ncfmigrate switch-ns %s\n```"},
+	{"godaddy", "In the GoDaddy Domain Portfolio, open the domain, choose DNS, then Nameservers, Change Nameservers and \"I'll use my own nameservers\"."},
+	{"namecheap", "In the Namecheap Domain List, choose Manage for the domain and set Nameservers to Custom DNS."},
+	{"squarespace", "In the Squarespace Domains panel, open the domain, choose DNS, then Domain Nameservers and Use Custom Nameservers."},
+	{"google", "Google Domains registrations moved to Squarespace: in the Squarespace Domains panel, open the domain, choose DNS, then Domain Nameservers and Use Custom Nameservers."},
+	{"gandi", "In the Gandi admin, open the domain, choose Nameservers, then Change and External."},
+	{"name.com", "In the Name.com account, open the domain, choose Manage Nameservers and replace the listed name servers."},
+	{"porkbun", "In the Porkbun Domain Management list, choose Details for the domain, then Authoritative Nameservers and Edit."},
+	{"network solutions", "In the Network Solutions Account Manager, open the domain, choose Change Where Domain Points, then Domain Name Server (DNS)."},
+	{"ovh", "In the OVHcloud control panel, open the domain, choose the DNS servers tab, then Modify DNS servers."},
+	{"tucows", "The domain is registered through a Tucows (OpenSRS, Hover) reseller. Change the name servers in the reseller's control panel."},
+	{"markmonitor", "MarkMonitor makes delegation changes on request: ask the account manager or open a request in MarkMonitor Domain Management."},
+	{"csc corporate", "CSC makes delegation changes on request: file a DNS change in CSCDomainManager or with the account manager."},
+}
+
+// lookupRegistrar returns the registrar of a domain from RDAP, trying the
+// parent names of a subdomain zone until one is registered.
+func lookupRegistrar(domain string) (string, error) {
+	client := &http.Client{Timeout: 15 * time.Second}
+
+	name := normalizeName(domain)
+	for strings.Count(name, ".") >= 1 {
+		resp, err := client.Get(rdapBootstrap + name)
+		if err != nil {
+			return "", fmt.Errorf("Unable to look up the registrar of %s: %s", name, err)
+		}
+
+		var result struct {
+			Entities []struct {
+				Roles      []string        `json:"roles"`
+				VCardArray json.RawMessage `json:"vcardArray"`
+			} `json:"entities"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			name = name[strings.Index(name, ".")+1:]
+			continue
+		}
+		if resp.StatusCode != http.StatusOK || err != nil {
+			return "", fmt.Errorf("Unable to look up the registrar of %s: %s", name, resp.Status)
+		}
+
+		for _, e := range result.Entities {
+			for _, role := range e.Roles {
+				if role == "registrar" {
+					return vcardName(e.VCardArray), nil
+				}
+			}
+		}
+
+		return "", fmt.Errorf("No registrar found for %s", name)
+	}
+
+	return "", fmt.Errorf("No registration found for %s", domain)
+}
+
+// vcardName returns the formatted name of a jCard, as RDAP describes
+// entities: ["vcard", [["fn", {}, "text", "Name"], ...]].
+func vcardName(raw json.RawMessage) string {
+	var card []interface{}
+	if json.Unmarshal(raw, &card) != nil || len(card) < 2 {
+		return ""
+	}
+
+	props, _ := card[1].([]interface{})
+	for _, p := range props {
+		prop, ok := p.([]interface{})
+		if ok && len(prop) >= 4 && prop[0] == "fn" {
+			if name, ok := prop[3].(string); ok {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+// registrarInstructions returns how to change the delegation of a domain at
+// its registrar, generic steps for registrars without their own. Commands
+// in the steps get the flags selecting the zone.
+func registrarInstructions(registrar, flags string) string {
+	lower := strings.ToLower(registrar)
+	for _, r := range registrarSteps {
+		if !strings.Contains(lower, r.match) {
+			continue
+		}
+
+		if strings.Contains(r.steps, "%s") {
+			return fmt.Sprintf(r.steps, flags)
+		}
+		return r.steps
+	}
+
+	return "Sign in at the registrar and replace the domain's name servers, usually under DNS or Nameservers settings."
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	restoreCmd.Flags().String("target", "", "Provider to restore the zones to, cloudflare or route53")
+	viper.BindPFlag("target", restoreCmd.Flags().Lookup("target"))
+
+	restoreCmd.Flags().String("from", "", "Provider whose zone files are restored, the target by default")
+	viper.BindPFlag("from", restoreCmd.Flags().Lookup("from"))
+
+	restoreCmd.Flags().Bool("all", false, "Restore every zone of the export instead of the --domain zones")
+	restoreCmd.Flags().String("dir", "", "Directory of the export to restore")
+	restoreCmd.Flags().Bool("dry-run", false, "Print the changes that would be made without applying them")
+
+	rootCmd.AddCommand(restoreCmd)
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Rebuild zones from the zone files of an export",
+	Long: `Restores the zones of an export directory to Cloudflare or Route53,
+creating zones that don't exist and the records missing from them, as
+migrate does. Records only in the provider are left alone. The files of
+the target provider are restored unless --from names the other one, and
+each is checked against the checksum of the manifest first.
+
+Route53 alias and routing policy record sets are only commented in the
+export and are listed to restore by hand. Private hosted zones are
+restored into a private zone serving their VPCs, created when missing,
+and never to Cloudflare. Cloudflare zones are created in
+the --cf-account-id account when set.
+
+Record values may hold placeholders such as {{ssm:///dns/api-target}} or
+{{arn:aws:secretsmanager:...}}, replaced with the SSM parameter or
+secret they name when restoring.`,
+	Args: cobra.NoArgs,
+	Run:  doRestore,
+}
+
+// parseZoneFile reads the records of a zone file written by export,
+// listing the commented out alias and routing policy records.
+func parseZoneFile(data []byte) ([]record, []string, error) {
+	records := make([]record, 0)
+	commented := make([]string, 0)
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		line := s.Text()
+		switch {
+		case strings.HasPrefix(line, "; ") && (strings.Contains(line, "\t") || strings.Contains(line, " ALIAS ")):
+			// aliases and routing policy records have no zone file form
+			commented = append(commented, strings.Join(strings.Fields(strings.TrimPrefix(line, "; ")), " "))
+			continue
+		case line == "", strings.HasPrefix(line, ";"), strings.HasPrefix(line, "$"):
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 || fields[2] != "IN" {
+			return nil, nil, fmt.Errorf("Invalid record on line %d: %s", n, line)
+		}
+
+		ttl, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid TTL on line %d: %s", n, line)
+		}
+
+		// the comment notes the proxy and automatic TTL of cloudflare
+		notes := make(map[string]bool)
+		if len(fields) > 5 {
+			for _, note := range strings.Split(strings.TrimPrefix(fields[5], "; "), ", ") {
+				notes[note] = true
+			}
+		}
+		if notes["auto TTL"] {
+			ttl = autoTTL
+		}
+
+		// values are kept in route53 presentation format, with octal TXT
+		// escapes
+		value := fields[4]
+		if fields[3] == "TXT" || fields[3] == "SPF" {
+			value = quoteTXT(parseZoneTXT(value))
+		}
+
+		records = append(records, record{
+			Name:    fields[0],
+			Type:    fields[3],
+			TTL:     ttl,
+			Value:   []string{value},
+			Proxied: notes["proxied"],
+		})
+	}
+
+	return records, commented, s.Err()
+}
+
+// restoreCloudflare creates the cloudflare zone when missing and the
+// records it lacks.
+func restoreCloudflare(cfg *config, name string, records []record, dryRun bool) error {
+	z := &zone{name: name, canonical: cfg.canonical}
+
+	for _, r := range records {
+		if (r.Type == "NS" || r.Type == "SOA") && equalNames(r.Name, name) {
+			continue
+		}
+		z.awsRecordSet = append(z.awsRecordSet, r)
+	}
+
+	if _, err := cfg.api.ZoneIDByName(name); err != nil {
+		fmt.Printf("  create cloudflare zone %s\n", name)
+		if dryRun {
+			plan, skipped := planZone(z)
+			printRestorePlan(plan, skipped)
+			return nil
+		}
+
+		if _, err := cfg.api.CreateZone(name, false, cloudflare.Organization{ID: cfg.cfAccountID}, "full"); err != nil {
+			return fmt.Errorf("Unable to create cloudflare zone %s: %s", name, err)
+		}
+	}
+
+	if err := fetchCloudflare(cfg, z, name); err != nil {
+		return err
+	}
+
+	plan, skipped := planZone(z)
+	printRestorePlan(plan, skipped)
+	if dryRun {
+		return nil
+	}
+
+	failed := 0
+	for _, c := range plan {
+		var err error
+		switch c.Action {
+		case "create":
+			_, err = cfg.api.CreateDNSRecord(z.zoneID, c.Record)
+		case "update":
+			err = cfg.api.UpdateDNSRecord(z.zoneID, c.Record.ID, c.Record)
+		}
+
+		if err != nil {
+			fmt.Printf("  failed: %s: %s\n", c, err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d changes to %s failed", failed, name)
+	}
+
+	return nil
+}
+
+func printRestorePlan(plan []change, skipped []string) {
+	for _, s := range skipped {
+		fmt.Printf("WARNING: skipping %s\n", s)
+	}
+	for _, c := range plan {
+		fmt.Printf("  %s\n", c)
+	}
+}
+
+// restoreChangeBatch is the number of record sets upserted per route53
+// request, well below its limit of 1000 changes.
+const restoreChangeBatch = 500
+
+// parseVPC splits a region/vpc-id of the manifest.
+func parseVPC(v string) (*route53.VPC, error) {
+	parts := strings.SplitN(v, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("Invalid VPC '%s' in the manifest", v)
+	}
+
+	return &route53.VPC{VPCRegion: aws.String(parts[0]), VPCId: aws.String(parts[1])}, nil
+}
+
+// findRestoreZone returns the ID of the hosted zone to restore into: the
+// public zone of the name, or for a private zone the private zone of the
+// name serving its first VPC. It is empty when there is none.
+func findRestoreZone(cfg *config, name string, vpcs []*route53.VPC) (string, error) {
+	out, err := cfg.r53.ListHostedZonesByName(&route53.ListHostedZonesByNameInput{DNSName: aws.String(name)})
+	if err != nil {
+		return "", err
+	}
+
+	for _, hz := range out.HostedZones {
+		if !equalNames(*hz.Name, name) || *hz.Config.PrivateZone != (len(vpcs) > 0) {
+			continue
+		}
+		if len(vpcs) == 0 {
+			return *hz.Id, nil
+		}
+
+		details, err := cfg.r53.GetHostedZone(&route53.GetHostedZoneInput{Id: hz.Id})
+		if err != nil {
+			return "", err
+		}
+		for _, v := range details.VPCs {
+			if aws.StringValue(v.VPCId) == *vpcs[0].VPCId {
+				return *hz.Id, nil
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// restoreRoute53 creates the hosted zone when missing and upserts the
+// record sets of the zone file. The apex NS and SOA records belong to the
+// hosted zone and are kept. A private zone is restored into a private zone
+// of its VPCs, never a public one.
+func restoreRoute53(cfg *config, name string, records []record, private []string, dryRun bool) error {
+	vpcs := make([]*route53.VPC, 0, len(private))
+	for _, v := range private {
+		vpc, err := parseVPC(v)
+		if err != nil {
+			return err
+		}
+		vpcs = append(vpcs, vpc)
+	}
+	sets, keys := groupRecords(records)
+
+	changes := make([]*route53.Change, 0, len(keys))
+	for _, k := range keys {
+		s := sets[k]
+		if (s.Type == "NS" || s.Type == "SOA") && equalNames(s.Name, name) {
+			continue
+		}
+
+		// route53 has no automatic TTL, it gets the TTL cloudflare serves
+		ttl := s.TTL
+		switch ttl {
+		case mixedTTL:
+			ttl = aliasTTL
+		case autoTTL:
+			ttl = autoTTLSeconds
+		}
+
+		rrs := &route53.ResourceRecordSet{
+			Name: aws.String(s.Name + "."),
+			Type: aws.String(s.Type),
+			TTL:  aws.Int64(int64(ttl)),
+		}
+		for _, v := range s.Value {
+			rrs.ResourceRecords = append(rrs.ResourceRecords, &route53.ResourceRecord{Value: aws.String(v)})
+		}
+
+		changes = append(changes, &route53.Change{Action: aws.String("UPSERT"), ResourceRecordSet: rrs})
+		fmt.Printf("  upsert %s %s %d %s\n", s.Name, s.Type, ttl, strings.Join(s.Value, ", "))
+	}
+
+	id, err := findRestoreZone(cfg, name, vpcs)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		input := &route53.CreateHostedZoneInput{
+			Name:            aws.String(name),
+			CallerReference: aws.String(fmt.Sprintf("cfmigrate-restore-%d", time.Now().UnixNano())),
+		}
+		if len(vpcs) > 0 {
+			fmt.Printf("  create private hosted zone %s for VPCs %s\n", name, strings.Join(private, ", "))
+			input.VPC = vpcs[0]
+			input.HostedZoneConfig = &route53.HostedZoneConfig{PrivateZone: aws.Bool(true)}
+		} else {
+			fmt.Printf("  create hosted zone %s\n", name)
+		}
+		if dryRun {
+			return nil
+		}
+
+		created, err := cfg.r53.CreateHostedZone(input)
+		if err != nil {
+			return fmt.Errorf("Unable to create hosted zone %s: %s", name, err)
+		}
+		id = *created.HostedZone.Id
+
+		for i := 1; i < len(vpcs); i++ {
+			v := vpcs[i]
+			_, err := cfg.r53.AssociateVPCWithHostedZone(&route53.AssociateVPCWithHostedZoneInput{HostedZoneId: aws.String(id), VPC: v})
+			if err != nil {
+				return fmt.Errorf("Unable to associate VPC %s with hosted zone %s: %s", *v.VPCId, name, err)
+			}
+		}
+	}
+
+	if dryRun {
+		return nil
+	}
+
+	for start := 0; start < len(changes); start += restoreChangeBatch {
+		end := start + restoreChangeBatch
+		if end > len(changes) {
+			end = len(changes)
+		}
+
+		_, err := cfg.r53.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+			HostedZoneId: aws.String(id),
+			ChangeBatch:  &route53.ChangeBatch{Changes: changes[start:end], Comment: aws.String("cfmigrate restore")},
+		})
+		if err != nil {
+			return fmt.Errorf("Unable to restore the records of %s: %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+func doRestore(cmd *cobra.Command, args []string) {
+	// these share their names with export and migrate flags bound to
+	// settings, so they are read from the command
+	all, _ := cmd.Flags().GetBool("all")
+	dir, _ := cmd.Flags().GetString("dir")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	target := viper.GetString("target")
+	if target != "cloudflare" && target != "route53" {
+		checkErr(fmt.Errorf("Unknown restore target '%s', use cloudflare or route53", target))
+	}
+	from := viper.GetString("from")
+	if from == "" {
+		from = target
+	}
+	if dir == "" {
+		checkErr(errors.New("No export directory supplied"))
+	}
+
+	if all && viper.GetString("domain") == "" && viper.GetString("group") == "" {
+		viper.Set("domain", "*")
+	}
+
+	cfg, err := assembleConfig()
+	checkErr(err)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	checkErr(err)
+
+	manifest := exportManifest{}
+	checkErr(json.Unmarshal(b, &manifest))
+
+	patterns := []string{cfg.domain}
+	if cfg.group != nil {
+		patterns = cfg.group.Domains
+	}
+
+	restored, failed := 0, 0
+	for _, e := range manifest.Zones {
+		if e.Provider != from {
+			continue
+		}
+
+		match := all
+		for _, p := range patterns {
+			if ok, _ := path.Match(strings.ToLower(p), e.Zone); ok {
+				match = true
+			}
+		}
+		if !match {
+			continue
+		}
+
+		fmt.Printf("Zone: %s from %s\n", e.Zone, e.File)
+		err := func() error {
+			data, err := ioutil.ReadFile(filepath.Join(dir, e.File))
+			if err != nil {
+				return err
+			}
+
+			sum := sha256.Sum256(data)
+			if hex.EncodeToString(sum[:]) != e.SHA256 {
+				return fmt.Errorf("%s does not match the checksum of the manifest", e.File)
+			}
+
+			records, commented, err := parseZoneFile(data)
+			if err != nil {
+				return fmt.Errorf("%s: %s", e.File, err)
+			}
+			if err := interpolateRecords(cfg, records); err != nil {
+				return err
+			}
+			for _, c := range commented {
+				fmt.Printf("  restore by hand: %s\n", c)
+			}
+
+			if target == "cloudflare" {
+				// cloudflare would publish the internal records
+				if e.Private {
+					return fmt.Errorf("%s is a private hosted zone, it is only restored to route53", e.Zone)
+				}
+				return restoreCloudflare(cfg, e.Zone, records, dryRun)
+			}
+			return restoreRoute53(cfg, e.Zone, records, e.VPCs, dryRun)
+		}()
+
+		if err != nil {
+			fmt.Printf("  failed: %s\n", err)
+			failed++
+			continue
+		}
+		restored++
+	}
+
+	fmt.Printf("Restored %d zones to %s\n", restored, target)
+	if failed > 0 {
+		checkErr(fmt.Errorf("%d zones failed to restore", failed))
+	}
+}
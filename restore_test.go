@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseZoneFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		records   []record
+		commented []string
+	}{
+		{"header and blank lines", "; route53 zone example.com (Z0) exported\n$ORIGIN example.com.\n\n", []record{}, []string{}},
+		{"records", "example.com.\t300\tIN\tA\t192.0.2.1\nwww.example.com.\t60\tIN\tCNAME\tweb.example.net.\n", []record{
+			{Name: "example.com.", Type: "A", TTL: 300, Value: []string{"192.0.2.1"}},
+			{Name: "www.example.com.", Type: "CNAME", TTL: 60, Value: []string{"web.example.net."}},
+		}, []string{}},
+		{"decimal TXT escapes", "example.com.\t300\tIN\tTXT\t\"caf\\195\\169\" \"!\"\n", []record{
+			{Name: "example.com.", Type: "TXT", TTL: 300, Value: []string{`"caf\303\251!"`}},
+		}, []string{}},
+		{"cloudflare notes", "www.example.com.\t300\tIN\tA\t192.0.2.1\t; proxied, auto TTL\napi.example.com.\t120\tIN\tA\t192.0.2.2\t; proxied\n", []record{
+			{Name: "www.example.com.", Type: "A", TTL: autoTTL, Value: []string{"192.0.2.1"}, Proxied: true},
+			{Name: "api.example.com.", Type: "A", TTL: 120, Value: []string{"192.0.2.2"}, Proxied: true},
+		}, []string{}},
+		{"commented records", "; lb.example.com. ALIAS lb.elb.amazonaws.com. (Z1)\n; geo.example.com.\t60\tIN\tA\t192.0.2.3\t; geolocation EU\n", []record{}, []string{
+			"lb.example.com. ALIAS lb.elb.amazonaws.com. (Z1)",
+			"geo.example.com. 60 IN A 192.0.2.3 ; geolocation EU",
+		}},
+	}
+
+	for _, tt := range tests {
+		records, commented, err := parseZoneFile([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(records, tt.records) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, records, tt.records)
+		}
+		if !reflect.DeepEqual(commented, tt.commented) {
+			t.Errorf("%s: got commented %q, want %q", tt.name, commented, tt.commented)
+		}
+	}
+}
+
+func TestParseZoneFileInvalid(t *testing.T) {
+	tests := []string{
+		"example.com. 300 IN A 192.0.2.1\n",
+		"example.com.\t300\tCH\tA\t192.0.2.1\n",
+		"example.com.\tlong\tIN\tA\t192.0.2.1\n",
+	}
+
+	for _, data := range tests {
+		if _, _, err := parseZoneFile([]byte(data)); err == nil {
+			t.Errorf("%q: parsed, want an error", data)
+		}
+	}
+}
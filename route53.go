@@ -0,0 +1,370 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/spf13/viper"
+)
+
+// aliasTTL is used for converted alias records, route53 does not give alias
+// records a TTL of their own.
+const aliasTTL = 300
+
+// unsupportedTypes are the route53 record types cloudflare can't serve,
+// with what to do instead.
+var unsupportedTypes = map[string]string{
+	"SPF": "cloudflare does not serve the SPF type, publish the policy as a TXT record",
+}
+
+// fetchRoute53 reads the record sets of the zone's hosted zone into the
+// zone's aws record set.
+func fetchRoute53(cfg *config, z *zone) error {
+	z.canonical = cfg.canonical
+
+	status.setPhase(z.name, "fetching route53 records")
+
+	sets := make([]*route53.ResourceRecordSet, 0)
+	err := cfg.r53.ListResourceRecordSetsPages(&route53.ListResourceRecordSetsInput{
+		HostedZoneId: aws.String(z.hostedZoneID),
+	}, func(page *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+		for _, r := range page.ResourceRecordSets {
+			// wildcards and other escaped names are kept as cloudflare
+			// shows them
+			r.Name = aws.String(unescapeName(*r.Name))
+
+			if z.subdomain != "" {
+				if !inZone(*r.Name, z.subdomain) {
+					continue
+				}
+
+				// the delegation stays behind in the parent zone
+				if *r.Type == "NS" && equalNames(*r.Name, z.subdomain) {
+					for _, rr := range r.ResourceRecords {
+						z.delegation = append(z.delegation, *rr.Value)
+					}
+					continue
+				}
+			}
+
+			// the apex NS and SOA records belong to whoever serves the zone
+			if (*r.Type == "NS" || *r.Type == "SOA") && equalNames(*r.Name, z.apex()) {
+				continue
+			}
+
+			if r.HealthCheckId != nil {
+				z.healthChecks = append(z.healthChecks, healthRef{
+					CheckID:       *r.HealthCheckId,
+					Name:          *r.Name,
+					Type:          *r.Type,
+					SetIdentifier: aws.StringValue(r.SetIdentifier),
+				})
+			}
+
+			sets = append(sets, r)
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	sets = excludeTrafficPolicies(cfg, z, sets)
+	sets = pickRouted(cfg, z, sets)
+
+	// a CNAME can't share its name with other records, aliases at such
+	// names have to be flattened instead
+	plain := make(map[string]bool)
+	for _, r := range sets {
+		if r.AliasTarget == nil {
+			plain[strings.ToLower(*r.Name)] = true
+		}
+	}
+
+	cnamed := make(map[string]bool)
+	for _, r := range sets {
+		if r.AliasTarget != nil {
+			convertAlias(cfg, z, r, plain, cnamed)
+			continue
+		}
+
+		if reason, ok := unsupportedTypes[*r.Type]; ok {
+			z.manual = append(z.manual, fmt.Sprintf("%s %s: %s", *r.Name, *r.Type, reason))
+			continue
+		}
+
+		values := make([]string, 0, len(r.ResourceRecords))
+		for _, rr := range r.ResourceRecords {
+			values = append(values, *rr.Value)
+		}
+
+		rec := record{
+			Name:  *r.Name,
+			Type:  *r.Type,
+			TTL:   int(aws.Int64Value(r.TTL)),
+			Value: values,
+		}
+		z.awsRecordSet = append(z.awsRecordSet, rec)
+		if rec.Type == "NS" {
+			z.subzones = append(z.subzones, rec)
+		}
+	}
+
+	// records are compared and migrated as they will be in cloudflare:
+	// with the TTL policy, then the transforms and last the proxy setting,
+	// proxied records always have the automatic TTL
+	ttl, proxied, err := zonePolicies(cfg, z)
+	if err != nil {
+		return err
+	}
+	for i, r := range z.awsRecordSet {
+		z.awsRecordSet[i].TTL = ttl.apply(r.Type, r.TTL)
+	}
+	applyTransforms(cfg.transforms, z)
+	if err := interpolateRecords(cfg, z.awsRecordSet); err != nil {
+		return err
+	}
+	for i, r := range z.awsRecordSet {
+		if proxied.match(z.apex(), r.Name, r.Type) {
+			z.awsRecordSet[i].Proxied = true
+			z.awsRecordSet[i].TTL = autoTTL
+		}
+	}
+	analyzeChains(z, cfg.flattenChain)
+	if cfg.dangling {
+		checkDangling(z)
+	}
+	if viper.GetBool("check-cloudfront") {
+		if err := checkCloudFront(cfg, z); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// convertAlias rewrites a route53 alias record, which has no cloudflare
+// equivalent, into a CNAME to the alias target or, when flattening or when
+// the name holds other records, the target's current addresses.
+//
+// The zone apex always holds other records but cloudflare flattens CNAMEs
+// there, so apex aliases become CNAMEs unless flattening was asked for.
+func convertAlias(cfg *config, z *zone, r *route53.ResourceRecordSet, plain, cnamed map[string]bool) {
+	name := strings.ToLower(*r.Name)
+	target := *r.AliasTarget.DNSName
+	apex := equalNames(name, z.apex())
+
+	note := ""
+	if aws.BoolValue(r.AliasTarget.EvaluateTargetHealth) {
+		note = " (target health evaluation is lost)"
+	}
+
+	flatten := cfg.flatten || (plain[name] && !apex)
+	if !flatten || (*r.Type != "A" && *r.Type != "AAAA") {
+		if plain[name] && !apex {
+			z.manual = append(z.manual, fmt.Sprintf("%s %s alias -> %s can not be converted, the name holds other records", *r.Name, *r.Type, target))
+			return
+		}
+
+		// A and AAAA aliases to the same target collapse into one CNAME
+		if cnamed[name] {
+			return
+		}
+		cnamed[name] = true
+
+		z.awsRecordSet = append(z.awsRecordSet, record{
+			Name:  *r.Name,
+			Type:  "CNAME",
+			TTL:   aliasTTL,
+			Value: []string{target},
+		})
+		if apex {
+			note = " APEX: relies on cloudflare CNAME flattening" + note
+		}
+		z.conversions = append(z.conversions, fmt.Sprintf("%s %s alias -> CNAME %s%s", *r.Name, *r.Type, target, note))
+		return
+	}
+
+	ips, err := net.LookupIP(strings.TrimSuffix(target, "."))
+	if err != nil {
+		z.manual = append(z.manual, fmt.Sprintf("%s %s alias -> %s could not be resolved: %s", *r.Name, *r.Type, target, err))
+		return
+	}
+
+	values := make([]string, 0)
+	for _, ip := range ips {
+		if (ip.To4() != nil) == (*r.Type == "A") {
+			values = append(values, ip.String())
+		}
+	}
+
+	if len(values) == 0 {
+		z.manual = append(z.manual, fmt.Sprintf("%s %s alias -> %s has no %s addresses", *r.Name, *r.Type, target, *r.Type))
+		return
+	}
+
+	z.awsRecordSet = append(z.awsRecordSet, record{
+		Name:  *r.Name,
+		Type:  *r.Type,
+		TTL:   aliasTTL,
+		Value: values,
+	})
+	if apex {
+		note = " APEX" + note
+	}
+	z.conversions = append(z.conversions, fmt.Sprintf("%s %s alias -> %s flattened to %s as currently resolved%s", *r.Name, *r.Type, target, strings.Join(values, ", "), note))
+}
+
+// routingPolicy describes the routing policy of a record set.
+func routingPolicy(r *route53.ResourceRecordSet) string {
+	switch {
+	case r.Failover != nil:
+		return "failover " + strings.ToLower(*r.Failover)
+	case r.Weight != nil:
+		return fmt.Sprintf("weight %d", *r.Weight)
+	case r.Region != nil:
+		return "latency " + *r.Region
+	case r.GeoLocation != nil:
+		g := r.GeoLocation
+		switch {
+		case g.ContinentCode != nil:
+			return "geolocation continent " + *g.ContinentCode
+		case aws.StringValue(g.CountryCode) == "*":
+			return "geolocation default"
+		case g.SubdivisionCode != nil:
+			return fmt.Sprintf("geolocation country %s subdivision %s", aws.StringValue(g.CountryCode), *g.SubdivisionCode)
+		}
+		return "geolocation country " + aws.StringValue(g.CountryCode)
+	case aws.BoolValue(r.MultiValueAnswer):
+		return "multivalue answer"
+	}
+
+	return "routing policy"
+}
+
+// pickRouted takes the record sets with a SetIdentifier out of sets, where
+// several of them share a name and type and would otherwise be merged. They
+// are reported. With create-load-balancers failover, weighted and continent
+// level geolocation sets are kept for migration as load balancers, and with
+// pick-routed the highest weight or primary set of weighted and failover
+// sets is kept as a plain record.
+func pickRouted(cfg *config, z *zone, sets []*route53.ResourceRecordSet) []*route53.ResourceRecordSet {
+	plain := make([]*route53.ResourceRecordSet, 0, len(sets))
+	routed := make(map[string][]*route53.ResourceRecordSet)
+	keys := make([]string, 0)
+	for _, r := range sets {
+		if r.SetIdentifier == nil {
+			plain = append(plain, r)
+			continue
+		}
+
+		k := recordKey(*r.Name, *r.Type)
+		if _, ok := routed[k]; !ok {
+			keys = append(keys, k)
+		}
+		routed[k] = append(routed[k], r)
+	}
+
+	for _, k := range keys {
+		if routed[k][0].GeoLocation != nil {
+			z.geo = append(z.geo, newGeoSet(routed[k], cfg.balance && balanceable(routed[k])))
+		}
+
+		if cfg.balance && balanceable(routed[k]) {
+			z.balanced = append(z.balanced, newBalancedSet(routed[k]))
+			for _, r := range routed[k] {
+				z.routed = append(z.routed, fmt.Sprintf("%s %s %s (%s): load balancer", *r.Name, *r.Type, *r.SetIdentifier, routingPolicy(r)))
+			}
+			continue
+		}
+
+		var pick *route53.ResourceRecordSet
+		for _, r := range routed[k] {
+			// route53 doesn't mix routing policies within a name and type
+			switch {
+			case r.Failover != nil:
+				if *r.Failover == "PRIMARY" {
+					pick = r
+				}
+			case r.Weight != nil:
+				if pick == nil || *r.Weight > aws.Int64Value(pick.Weight) {
+					pick = r
+				}
+			}
+		}
+		if !cfg.pickRouted {
+			pick = nil
+		}
+
+		for _, r := range routed[k] {
+			action := "not migrated"
+			switch {
+			case r.GeoLocation != nil && cfg.balance:
+				action = "not migrated, country rules have no cloudflare region"
+			case r == pick:
+				action = "migrated"
+			case pick != nil:
+				action = "dropped"
+			}
+			desc := fmt.Sprintf("%s %s %s (%s): %s", *r.Name, *r.Type, *r.SetIdentifier, routingPolicy(r), action)
+			if strings.HasPrefix(action, "not migrated") {
+				z.manual = append(z.manual, desc)
+			} else {
+				z.routed = append(z.routed, desc)
+			}
+		}
+
+		if pick != nil {
+			plain = append(plain, pick)
+		}
+	}
+
+	return plain
+}
+
+// excludeTrafficPolicies takes the record sets created by traffic policy
+// instances out of sets, listing them with their policy for manual action.
+// Their values are the output of the policy's rules, migrating them as
+// plain records would lose the rules.
+func excludeTrafficPolicies(cfg *config, z *zone, sets []*route53.ResourceRecordSet) []*route53.ResourceRecordSet {
+	plain := make([]*route53.ResourceRecordSet, 0, len(sets))
+	policies := make(map[string]string)
+	for _, r := range sets {
+		if r.TrafficPolicyInstanceId == nil {
+			plain = append(plain, r)
+			continue
+		}
+
+		id := *r.TrafficPolicyInstanceId
+		if _, ok := policies[id]; !ok {
+			policies[id] = trafficPolicyName(cfg, id)
+		}
+
+		z.manual = append(z.manual, fmt.Sprintf("%s %s: managed by traffic policy %s (instance %s), not migrated", *r.Name, *r.Type, policies[id], id))
+	}
+
+	return plain
+}
+
+// trafficPolicyName looks up the name and version of the policy of a
+// traffic policy instance, falling back to the policy ID.
+func trafficPolicyName(cfg *config, instanceID string) string {
+	instance, err := cfg.r53.GetTrafficPolicyInstance(&route53.GetTrafficPolicyInstanceInput{Id: aws.String(instanceID)})
+	if err != nil {
+		return "unknown"
+	}
+
+	tpi := instance.TrafficPolicyInstance
+	policy, err := cfg.r53.GetTrafficPolicy(&route53.GetTrafficPolicyInput{
+		Id:      tpi.TrafficPolicyId,
+		Version: tpi.TrafficPolicyVersion,
+	})
+	if err != nil {
+		return fmt.Sprintf("%s version %d", *tpi.TrafficPolicyId, *tpi.TrafficPolicyVersion)
+	}
+
+	return fmt.Sprintf("%s version %d", *policy.TrafficPolicy.Name, *tpi.TrafficPolicyVersion)
+}
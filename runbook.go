@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(runbookCmd)
+}
+
+var runbookCmd = &cobra.Command{
+	Use:   "runbook",
+	Short: "Print a markdown cutover runbook for each zone",
+	Long: `Prints a step by step cutover runbook in markdown for each selected zone,
+tailored to what the zone holds: records needing manual action, health
+checks, routing policy sets, proxied records, mail records, DNSSEC and the
+current delegation. Each step carries the cfmigrate command to run, with the
+migration flags given to runbook. Credentials given on the command line are
+left out of the commands.`,
+	Run: doRunbook,
+}
+
+// runbookSkipFlags are left out of the commands of the runbook: the zone
+// selection, given per zone, credentials, which don't belong in a shared
+// document, and where this run's reports go.
+var runbookSkipFlags = map[string]bool{
+	"domain": true, "group": true, "subdomain": true, "hosted-zone-id": true, "profile": true,
+	"cfkey": true, "cftoken": true, "awskey": true, "awssecret": true, "aws-mfa-code": true, "vault-token": true,
+	"output": true, "github-pr": true, "gitlab-mr": true, "email-to": true,
+}
+
+// migrateFlags returns the flags shaping the migration of a zone, as given
+// on this run, for the commands of the runbook. Every migrate flag set on
+// the command line is passed on. Settings of the config file apply to the
+// commands as they do to this run.
+func migrateFlags(cfg *config, z *zone) string {
+	flags := []string{"--domain " + z.name}
+	if z.subdomain != "" {
+		flags = append(flags, "--subdomain "+z.subdomain)
+	}
+	if cfg.hostedZoneID != "" {
+		flags = append(flags, "--hosted-zone-id "+cfg.hostedZoneID)
+	}
+
+	// with several profiles this is the one of the zone
+	if p := viper.GetString("profile"); p != "" {
+		flags = append(flags, "--profile "+shellQuote(p))
+	}
+
+	for _, fs := range []*pflag.FlagSet{rootCmd.PersistentFlags(), migrateCmd.Flags()} {
+		fs.VisitAll(func(f *pflag.Flag) {
+			if runbookSkipFlags[f.Name] || !flagChanged(f.Name) {
+				return
+			}
+
+			switch f.Value.Type() {
+			case "bool":
+				if v, _ := cmdFlags.GetBool(f.Name); v {
+					flags = append(flags, "--"+f.Name)
+				} else {
+					flags = append(flags, "--"+f.Name+"=false")
+				}
+			case "stringSlice":
+				values, _ := cmdFlags.GetStringSlice(f.Name)
+				for _, v := range values {
+					flags = append(flags, "--"+f.Name+" "+shellQuote(v))
+				}
+			default:
+				flags = append(flags, "--"+f.Name+" "+shellQuote(cmdFlags.Lookup(f.Name).Value.String()))
+			}
+		})
+	}
+
+	return strings.Join(flags, " ")
+}
+
+// shellQuote quotes a value for a shell when it holds more than letters,
+// digits and the punctuation of names, paths and durations.
+func shellQuote(v string) string {
+	for _, c := range v {
+		if !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.,/:=@", c) {
+			return "'" + strings.Replace(v, "'", `'\''`, -1) + "'"
+		}
+	}
+	if v == "" {
+		return "''"
+	}
+	return v
+}
+
+// renderRunbook writes the runbook of a loaded zone.
+func renderRunbook(cfg *config, z *zone) (string, error) {
+	flags := migrateFlags(cfg, z)
+	b := &bytes.Buffer{}
+	step := 0
+	section := func(title string) {
+		step++
+		fmt.Fprintf(b, "\n## %d. %s\n\n", step, title)
+	}
+
+	fmt.Fprintf(b, "# Cutover runbook for %s\n\n", z.apex())
+	fmt.Fprintf(b, "%d route53 records, %d cloudflare records.\n", len(z.awsRecordSet), len(z.cfRecordSet))
+
+	section("Review the differences")
+	fmt.Fprintf(b, "```\ncfmigrate compare %s\n```\n", flags)
+	if len(z.problems) > 0 {
+		fmt.Fprintf(b, "\nFix these record problems first:\n\n")
+		for _, p := range z.problems {
+			fmt.Fprintf(b, "- %s\n", p)
+		}
+	}
+
+	if len(z.manual) > 0 {
+		section("Handle what can't be migrated")
+		for _, m := range z.manual {
+			fmt.Fprintf(b, "- [ ] %s\n", m)
+		}
+	}
+
+	if len(z.healthChecks) > 0 {
+		section("Create monitors for the health checks")
+		fmt.Fprintf(b, "%d record sets use route53 health checks.\n\n", len(z.healthChecks))
+		fmt.Fprintf(b, "```\ncfmigrate health-checks %s --create-monitors\n```\n", flags)
+	}
+
+	if len(z.routed) > 0 || len(z.balanced) > 0 {
+		section("Decide on the routing policy records")
+		for _, r := range z.routed {
+			fmt.Fprintf(b, "- %s\n", r)
+		}
+		for _, s := range z.balanced {
+			fmt.Fprintf(b, "- %s: migrated as a load balancer\n", s)
+		}
+		if !cfg.balance && !cfg.pickRouted {
+			fmt.Fprintf(b, "\nRerun runbook with --create-load-balancers to migrate them as load balancers, or --pick-routed to keep one record of each set.\n")
+		}
+	}
+
+	candidates := 0
+	for _, r := range z.awsRecordSet {
+		if proxiable(r.Type) && !r.Proxied {
+			candidates++
+		}
+	}
+	if candidates > 0 && len(cfg.proxied) == 0 {
+		section("Choose the proxied records")
+		fmt.Fprintf(b, "%d A, AAAA and CNAME records could be proxied by cloudflare. All records are created DNS only unless selected with --proxied.\n", candidates)
+	}
+
+	maxTTL := 0
+	for _, r := range z.awsRecordSet {
+		if r.TTL > maxTTL {
+			maxTTL = r.TTL
+		}
+	}
+	section("Lower the TTLs")
+	fmt.Fprintf(b, "The highest TTL in the zone is %d seconds. Lower long TTLs in route53 at least that long before the cutover, so a rollback takes effect quickly.\n", maxTTL)
+
+	section("Migrate the records")
+	fmt.Fprintf(b, "```\ncfmigrate migrate %s --dry-run\ncfmigrate migrate %s\ncfmigrate compare %s --live\n```\n", flags, flags, flags)
+
+	if len(withPrefix(mailTexts(z.awsRecordSet, normalizeName(z.apex()), "TXT"), "v=spf1")) > 0 {
+		section("Check the mail records")
+		fmt.Fprintf(b, "```\ncfmigrate validate-email %s\n```\n", flags)
+	}
+
+	details, err := cfg.api.ZoneDetails(z.zoneID)
+	if err != nil {
+		return "", err
+	}
+
+	dnssec, err := zoneDNSSEC(cfg, z.zoneID, "")
+	if err != nil {
+		return "", err
+	}
+
+	if z.subdomain != "" {
+		section("Delegate the subdomain")
+		fmt.Fprintf(b, "Add these NS records to the route53 zone %s:\n\n```\n", z.name)
+		for _, ns := range details.NameServers {
+			fmt.Fprintf(b, "%s. NS %s.\n", z.subdomain, strings.TrimSuffix(ns, "."))
+		}
+		fmt.Fprintf(b, "```\n")
+	} else {
+		section("Switch the name servers at the registrar")
+		if current, err := net.LookupNS(z.apex()); err == nil {
+			names := make([]string, 0, len(current))
+			for _, ns := range current {
+				names = append(names, strings.TrimSuffix(ns.Host, "."))
+			}
+			fmt.Fprintf(b, "Currently delegated to %s.\n\n", strings.Join(names, ", "))
+		}
+		fmt.Fprintf(b, "Replace them with the cloudflare name servers:\n\n")
+		for _, ns := range details.NameServers {
+			fmt.Fprintf(b, "- %s\n", ns)
+		}
+
+		if registrar, err := lookupRegistrar(z.name); err == nil && registrar != "" {
+			fmt.Fprintf(b, "\nThe registrar is %s. %s\n", registrar, registrarInstructions(registrar, flags))
+		} else {
+			fmt.Fprintf(b, "\n%s\n", registrarInstructions("", flags))
+		}
+	}
+
+	section("Set up DNSSEC")
+	fmt.Fprintf(b, "If the zone is signed at route53, remove its DS record at the registrar and wait out the DS TTL before switching name servers.\n\n")
+	if dnssec.Status == "disabled" {
+		fmt.Fprintf(b, "Cloudflare DNSSEC is off. Enable it after the switch and add the DS record it shows at the registrar:\n\n")
+		fmt.Fprintf(b, "```\ncfmigrate dnssec %s --enable\n```\n", flags)
+	} else {
+		fmt.Fprintf(b, "Cloudflare DNSSEC is %s. Add this DS record at the registrar after the switch:\n\n```\n%s\n```\n", dnssec.Status, dnssec.DS)
+	}
+
+	section("Verify after the switch")
+	fmt.Fprintf(b, "Wait for the old NS records to expire, up to 48 hours, then compare again:\n\n")
+	fmt.Fprintf(b, "```\ncfmigrate compare %s --live\n```\n", flags)
+
+	return b.String(), nil
+}
+
+func doRunbook(cmd *cobra.Command, args []string) {
+	runZones(func(cfg *config, z *zone) error {
+		if err := loadZone(cfg, z); err != nil {
+			return err
+		}
+		validateZone(z)
+
+		runbook, err := renderRunbook(cfg, z)
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(runbook)
+		return nil
+	})
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema [report|plan]",
+	Short: "Print the JSON Schema of a JSON output",
+	Long: `Prints the JSON Schema (draft-07) of the report written by compare with
+--output json, or of the plan written by migrate with --plan-json. The
+version field of either changes whenever its schema changes incompatibly.`,
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: []string{"report", "plan"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{"report"}
+		}
+
+		schema, ok := schemas[args[0]]
+		if !ok {
+			checkErr(fmt.Errorf("Unknown schema '%s', use report or plan", args[0]))
+		}
+		fmt.Print(schema)
+	},
+}
+
+// schemas are the JSON Schemas of the outputs by name.
+var schemas = map[string]string{
+	"report": reportSchema,
+	"plan":   planSchema,
+}
+
+// reportSchema describes jsonReport, keep it in step with the types in
+// json.go.
+const reportSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://github.com/lordnynex/cfmigrate/schema/report-2.json",
+  "title": "cfmigrate compare report",
+  "type": "object",
+  "required": ["version", "zones"],
+  "additionalProperties": false,
+  "properties": {
+    "version": {"const": 2},
+    "zones": {"type": "array", "items": {"$ref": "#/definitions/zone"}}
+  },
+  "definitions": {
+    "zone": {
+      "type": "object",
+      "required": ["zone", "matching", "diffs", "conversions", "routed", "manual", "subzones"],
+      "additionalProperties": false,
+      "properties": {
+        "zone": {"type": "string", "description": "Name of the cloudflare zone"},
+        "stale": {"type": "string", "format": "date-time", "description": "When the cached cloudflare records used were fetched, absent for live data"},
+        "matching": {"type": "integer", "minimum": 0, "description": "Number of record sets equal in both providers"},
+        "diffs": {"type": "array", "items": {"$ref": "#/definitions/diff"}},
+        "conversions": {"type": "array", "items": {"type": "string"}, "description": "Route53 alias records converted for cloudflare"},
+        "routed": {"type": "array", "items": {"type": "string"}, "description": "Routing policy record sets and how they are handled"},
+        "manual": {"type": "array", "items": {"type": "string"}, "description": "Record sets that can't be translated and need manual action"},
+        "subzones": {"type": "array", "items": {"$ref": "#/definitions/set"}, "description": "NS record sets delegating subdomains"},
+        "settling": {"type": "array", "items": {"type": "string"}, "description": "Differing record sets changed within the --settle window, left out of the diffs"}
+      }
+    },
+    "diff": {
+      "type": "object",
+      "required": ["name", "type", "route53", "cloudflare", "missing", "extra"],
+      "additionalProperties": false,
+      "properties": {
+        "name": {"type": "string"},
+        "type": {"type": "string"},
+        "route53": {"oneOf": [{"$ref": "#/definitions/set"}, {"type": "null"}], "description": "Null when the set is only in cloudflare"},
+        "cloudflare": {"oneOf": [{"$ref": "#/definitions/set"}, {"type": "null"}], "description": "Null when the set is missing in cloudflare"},
+        "missing": {"type": "array", "items": {"type": "string"}, "description": "Normalized route53 values cloudflare lacks"},
+        "extra": {"type": "array", "items": {"type": "string"}, "description": "Normalized values only cloudflare has"},
+        "route53_change": {"type": "string", "description": "Who last changed the route53 set and when, from CloudTrail with --cloudtrail"}
+      }
+    },
+    "set": {
+      "type": "object",
+      "required": ["name", "ttl", "values", "proxied"],
+      "additionalProperties": false,
+      "properties": {
+        "name": {"type": "string"},
+        "ttl": {"type": "integer", "minimum": -1, "description": "-1 when the values of the set have different TTLs"},
+        "values": {"type": "array", "items": {"type": "string"}, "description": "Values in zone file presentation format"},
+        "proxied": {"type": "boolean", "description": "Whether cloudflare proxies the set, for route53 whether the proxied rules ask for it"}
+      }
+    }
+  }
+}
+`
+
+// planSchema describes jsonPlan, keep it in step with the types in json.go.
+const planSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "$id": "https://github.com/lordnynex/cfmigrate/schema/plan-1.json",
+  "title": "cfmigrate migrate plan",
+  "type": "object",
+  "required": ["version", "zones"],
+  "additionalProperties": false,
+  "properties": {
+    "version": {"const": 1},
+    "zones": {"type": "array", "items": {"$ref": "#/definitions/zone"}}
+  },
+  "definitions": {
+    "zone": {
+      "type": "object",
+      "required": ["zone", "changes", "load_balancers", "skipped", "warnings"],
+      "additionalProperties": false,
+      "properties": {
+        "zone": {"type": "string", "description": "Name of the cloudflare zone"},
+        "changes": {"type": "array", "items": {"$ref": "#/definitions/change"}},
+        "load_balancers": {"type": "array", "items": {"type": "string"}, "description": "Load balancers created for routing policy record sets"},
+        "skipped": {"type": "array", "items": {"type": "string"}, "description": "Values that can't be converted for cloudflare"},
+        "warnings": {"type": "array", "items": {"type": "string"}, "description": "What --strict fails the zone for"}
+      }
+    },
+    "change": {
+      "type": "object",
+      "required": ["action", "name", "type", "ttl", "value", "proxied"],
+      "additionalProperties": false,
+      "properties": {
+        "action": {"enum": ["create", "update"]},
+        "name": {"type": "string"},
+        "type": {"type": "string"},
+        "ttl": {"type": "integer", "minimum": 0, "description": "1 is automatic for cloudflare"},
+        "value": {"type": "string", "description": "Value in zone file presentation format"},
+        "proxied": {"type": "boolean"}
+      }
+    }
+  }
+}
+`
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().Duration("settle", 0, "Leave out differences whose records changed within this long, such as 10m, as still in flux. Route53 changes are only seen with --cloudtrail, which logs them after a delay of a few minutes")
+	viper.BindPFlag("settle", rootCmd.PersistentFlags().Lookup("settle"))
+}
+
+// settleZone moves the differences of a zone whose records changed within
+// the window out of its diffs, so automation writing to both providers a
+// little apart doesn't show as drift. Route53 keeps no change time per
+// record, with --cloudtrail the time of its last ChangeResourceRecordSets
+// event is used. A set deleted from cloudflare can't be told apart.
+func settleZone(cfg *config, z *zone, window time.Duration) error {
+	if window <= 0 {
+		return nil
+	}
+
+	since := time.Now().Add(-window)
+
+	changed := make(map[string]time.Time)
+	if viper.GetBool("cloudtrail") {
+		changes, err := fetchTrail(cfg, time.Now().Add(-viper.GetDuration("audit-since")))
+		if err != nil {
+			return err
+		}
+
+		zoneID := strings.TrimPrefix(z.hostedZoneID, "/hostedzone/")
+		for _, c := range changes {
+			// events are newest first
+			if _, ok := changed[c.key]; !ok && c.zoneID == zoneID {
+				changed[c.key] = c.when
+			}
+		}
+	}
+
+	diffs := make([]recordDiff, 0, len(z.diffs))
+	for _, d := range z.diffs {
+		if d.Cloudflare != nil && d.Cloudflare.Modified.After(since) {
+			z.settling = append(z.settling, fmt.Sprintf("%s %s, changed in cloudflare at %s", d.Name, d.Type, d.Cloudflare.Modified.Format(time.RFC3339)))
+			continue
+		}
+		if when := changed[recordKey(d.Name, d.Type)]; when.After(since) {
+			z.settling = append(z.settling, fmt.Sprintf("%s %s, changed in route53 at %s", d.Name, d.Type, when.Format(time.RFC3339)))
+			continue
+		}
+		diffs = append(diffs, d)
+	}
+
+	z.diffs = diffs
+
+	return nil
+}
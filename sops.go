@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	configSetting("sops", false)
+}
+
+// decryptConfig replaces the settings read from a SOPS encrypted config
+// file with its decrypted contents. The sops binary does the decryption,
+// so age, PGP and cloud KMS keys work as sops is set up for them, and the
+// plaintext is only ever held in memory.
+func decryptConfig() error {
+	if !viper.InConfig("sops") {
+		return nil
+	}
+
+	file := viper.ConfigFileUsed()
+	out, err := sopsDecrypt(file)
+	if err != nil {
+		return err
+	}
+
+	if err := viper.ReadConfig(bytes.NewReader(out)); err != nil {
+		return fmt.Errorf("Unable to read decrypted config file %s: %s", file, err)
+	}
+
+	return nil
+}
+
+// sopsDecrypt returns the decrypted contents of a SOPS encrypted file.
+func sopsDecrypt(file string) ([]byte, error) {
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command("sops", "--decrypt", file)
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt config file %s with sops: %s %s", file, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// runStatus tracks the progress of a run. It is logged periodically as a
+// heartbeat and mirrored to the status file so supervisors can tell a slow
+// run from a hung one.
+type runStatus struct {
+	mu   sync.Mutex
+	file string
+
+	Started    time.Time `json:"started"`
+	Updated    time.Time `json:"updated"`
+	Phase      string    `json:"phase"`
+	Zone       string    `json:"zone,omitempty"`
+	ZonesDone  int       `json:"zones_done"`
+	ZonesTotal int       `json:"zones_total"`
+	Error      string    `json:"error,omitempty"`
+}
+
+var status = &runStatus{}
+
+// start begins heartbeat logging every interval and writing the status
+// file, either of which may be disabled with a zero value.
+func (s *runStatus) start(interval time.Duration, file string) {
+	s.mu.Lock()
+	s.file = file
+	s.Started = time.Now()
+	s.Updated = s.Started
+	s.Phase = "starting"
+	s.write()
+	s.mu.Unlock()
+
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			s.mu.Lock()
+			fmt.Fprintf(os.Stderr, "heartbeat: phase=%q zone=%q zones=%d/%d elapsed=%s\n",
+				s.Phase, s.Zone, s.ZonesDone, s.ZonesTotal, time.Since(s.Started).Round(time.Second))
+			s.write()
+			s.mu.Unlock()
+		}
+	}()
+}
+
+// setPhase records the phase the run is currently in.
+func (s *runStatus) setPhase(zone, phase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Zone = zone
+	s.Phase = phase
+	s.Updated = time.Now()
+	s.write()
+}
+
+// setZones records how many zones the run covers.
+func (s *runStatus) setZones(total int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ZonesTotal = total
+	s.write()
+}
+
+// zoneDone marks one more zone as finished.
+func (s *runStatus) zoneDone() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ZonesDone++
+	s.Updated = time.Now()
+	s.write()
+}
+
+// fail records the error that ended the run.
+func (s *runStatus) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Phase = "failed"
+	s.Error = err.Error()
+	s.Updated = time.Now()
+	s.write()
+}
+
+// phase returns the phase the run is in.
+func (s *runStatus) phase() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.Phase
+}
+
+// write replaces the status file, the caller must hold the lock. The file
+// is renamed into place so readers never see a partial write.
+func (s *runStatus) write() {
+	if s.file == "" {
+		return
+	}
+
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(s.file), ".cfmigrate-status")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to write status file:", err)
+		return
+	}
+
+	_, err = tmp.Write(b)
+	tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		fmt.Fprintln(os.Stderr, "Unable to write status file:", err)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().Bool("strict", false, "Fail on any warning: records needing manual action, converted aliases, routing policy records dropped by --pick-routed, transformed records, records only matching through canonicalize rules, CNAME chains, record problems, settling or skipped records, health checks left out of load balancers or stale cloudflare data")
+	viper.BindPFlag("strict", rootCmd.PersistentFlags().Lookup("strict"))
+}
+
+// zoneWarnings lists what of a zone doesn't migrate exactly as route53
+// serves it, with the records a plan skipped.
+func zoneWarnings(z *zone, skipped []string) []string {
+	warnings := make([]string, 0)
+	for _, m := range z.manual {
+		warnings = append(warnings, "needs manual action: "+m)
+	}
+	for _, c := range z.conversions {
+		warnings = append(warnings, "converted alias: "+c)
+	}
+	for _, r := range z.routed {
+		// --pick-routed migrates one set of a name, dropping the others
+		if strings.HasSuffix(r, ": dropped") {
+			warnings = append(warnings, "dropped routing policy record: "+strings.TrimSuffix(r, ": dropped"))
+		}
+	}
+	for _, t := range z.transformed {
+		warnings = append(warnings, "transformed: "+t)
+	}
+	for _, n := range normalizedSets(z) {
+		warnings = append(warnings, "normalized: "+n)
+	}
+	for _, c := range z.chains {
+		warnings = append(warnings, "CNAME chain: "+c)
+	}
+	for _, p := range z.problems {
+		warnings = append(warnings, "record problem: "+p)
+	}
+	for _, s := range z.settling {
+		warnings = append(warnings, "settling: "+s)
+	}
+	for _, s := range skipped {
+		warnings = append(warnings, "skipped: "+s)
+	}
+	for _, w := range z.warnings {
+		warnings = append(warnings, "load balancer: "+w)
+	}
+	if !z.stale.IsZero() {
+		warnings = append(warnings, "stale cloudflare data from "+z.stale.Format(time.RFC3339))
+	}
+
+	return warnings
+}
+
+// normalizedSets lists the record sets that only match cloudflare through
+// the canonicalize rules of the config file, which may hide real changes.
+func normalizedSets(z *zone) []string {
+	if len(z.canonical) == 0 {
+		return nil
+	}
+
+	r53, keys := groupRecords(z.awsRecordSet)
+	cf, _ := groupRecords(z.cfRecordSet)
+
+	canonical := func(typ string, values []string) string {
+		out := make([]string, 0, len(values))
+		for _, v := range values {
+			out = append(out, canonicalValue(typ, v))
+		}
+		sort.Strings(out)
+		return strings.Join(out, "\n")
+	}
+
+	sets := make([]string, 0)
+	for _, k := range keys {
+		a, c := r53[k], cf[k]
+		if c == nil || !equalValues(z.canonical, a.Type, a.Value, c.Value) {
+			continue
+		}
+		if canonical(a.Type, a.Value) != canonical(a.Type, c.Value) {
+			sets = append(sets, fmt.Sprintf("%s %s", a.Name, a.Type))
+		}
+	}
+
+	return sets
+}
+
+// strictCheck fails a zone with warnings when --strict is set.
+func strictCheck(z *zone, skipped []string) error {
+	if !viper.GetBool("strict") {
+		return nil
+	}
+
+	warnings := zoneWarnings(z, skipped)
+	if len(warnings) == 0 {
+		return nil
+	}
+
+	fmt.Println("Strict mode warnings:")
+	for _, w := range warnings {
+		fmt.Printf("  %s\n", w)
+	}
+
+	return fmt.Errorf("%s has %d warnings, failing in strict mode", z.apex(), len(warnings))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	switchNSCmd.Flags().Bool("back", false, "Switch the name servers back to the Route53 hosted zone")
+	switchNSCmd.Flags().Bool("dry-run", false, "Print the name servers that would be set without changing them")
+
+	rootCmd.AddCommand(switchNSCmd)
+}
+
+var switchNSCmd = &cobra.Command{
+	Use:   "switch-ns",
+	Short: "Point the domain at the Cloudflare name servers at Route53 Domains",
+	Long: `For domains registered with Route53 Domains, sets the name servers at the
+registrar to the ones Cloudflare assigned to the zone, completing the
+cutover. --back sets them to the name servers of the Route53 hosted zone
+again. Remove a DS record of a zone signed at route53 first, see runbook.
+
+Route53 Domains takes a while to apply the change, the operation ID it
+returns can be followed in the Route53 console.`,
+	Args: cobra.NoArgs,
+	Run:  doSwitchNS,
+}
+
+// route53DomainsRegion is the only region serving the Route53 Domains API.
+const route53DomainsRegion = "us-east-1"
+
+// domainNameservers returns the name servers the registrar delegates a
+// domain registered with Route53 Domains to.
+func domainNameservers(cfg *config, domain string) ([]string, error) {
+	var out struct {
+		Nameservers []struct {
+			Name string
+		}
+	}
+
+	in := map[string]interface{}{"DomainName": domain}
+	if err := awsJSONCall(cfg.session, "route53domains", route53DomainsRegion, "Route53Domains_v20140515.GetDomainDetail", in, &out); err != nil {
+		return nil, fmt.Errorf("Unable to read the Route53 Domains registration of %s: %s", domain, err)
+	}
+
+	names := make([]string, 0, len(out.Nameservers))
+	for _, ns := range out.Nameservers {
+		names = append(names, ns.Name)
+	}
+
+	return names, nil
+}
+
+// updateNameservers sets the name servers of a domain registered with
+// Route53 Domains, returning the ID of the operation.
+func updateNameservers(cfg *config, domain string, names []string) (string, error) {
+	nameservers := make([]map[string]interface{}, 0, len(names))
+	for _, ns := range names {
+		nameservers = append(nameservers, map[string]interface{}{"Name": strings.TrimSuffix(ns, ".")})
+	}
+
+	var out struct {
+		OperationID string `json:"OperationId"`
+	}
+
+	in := map[string]interface{}{"DomainName": domain, "Nameservers": nameservers}
+	if err := awsJSONCall(cfg.session, "route53domains", route53DomainsRegion, "Route53Domains_v20140515.UpdateDomainNameservers", in, &out); err != nil {
+		return "", fmt.Errorf("Unable to update the name servers of %s: %s", domain, err)
+	}
+
+	return out.OperationID, nil
+}
+
+func doSwitchNS(cmd *cobra.Command, args []string) {
+	back, _ := cmd.Flags().GetBool("back")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+	runZones(func(cfg *config, z *zone) error {
+		if z.subdomain != "" {
+			return errors.New("switch-ns changes the registration of a domain, a subdomain is delegated in route53 instead")
+		}
+
+		domain := normalizeName(z.name)
+
+		var target []string
+		if back {
+			out, err := cfg.r53.GetHostedZone(&route53.GetHostedZoneInput{Id: aws.String(z.hostedZoneID)})
+			if err != nil {
+				return fmt.Errorf("Unable to read hosted zone %s: %s", z.hostedZoneID, err)
+			}
+			if out.DelegationSet == nil {
+				return fmt.Errorf("Hosted zone %s has no name servers, private zones can't be delegated to", z.hostedZoneID)
+			}
+			target = aws.StringValueSlice(out.DelegationSet.NameServers)
+		} else {
+			zoneID, err := cfg.api.ZoneIDByName(domain)
+			if err != nil {
+				return fmt.Errorf("No cloudflare zone %s: %s", domain, err)
+			}
+
+			details, err := cfg.api.ZoneDetails(zoneID)
+			if err != nil {
+				return err
+			}
+			target = details.NameServers
+		}
+
+		current, err := domainNameservers(cfg, domain)
+		if err != nil {
+			return err
+		}
+
+		if equalNameSets(current, target) {
+			fmt.Printf("%s already uses %s\n", domain, strings.Join(target, ", "))
+			return nil
+		}
+
+		fmt.Printf("Name servers of %s:\n", domain)
+		fmt.Printf("  current: %s\n", strings.Join(current, ", "))
+		fmt.Printf("  new:     %s\n", strings.Join(target, ", "))
+
+		if dryRun {
+			return nil
+		}
+
+		id, err := updateNameservers(cfg, domain, target)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Requested the change, Route53 Domains operation %s\n", id)
+		return nil
+	})
+}
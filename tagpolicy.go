@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	configSetting("tag-policies", false)
+}
+
+// tagPolicy is an entry of the tag-policies section of the config file,
+// giving the hosted zones carrying all of its tags their own TTL policy
+// and proxied rules. The first policy matching a zone applies. Settings
+// it leaves out, or that were given on the command line, keep the values
+// of the run. Tag keys match regardless of case.
+//
+//	tag-policies:
+//	  - tags: {env: prod}
+//	    min-ttl: 300
+//	    proxied: ["@", "www"]
+//	  - tags: {env: dev}
+//	    ttl-override: [TXT=auto]
+//	    proxied: []
+type tagPolicy struct {
+	Tags        map[string]string
+	MinTTL      *int      `mapstructure:"min-ttl" yaml:"min-ttl"`
+	TTLOverride *[]string `mapstructure:"ttl-override" yaml:"ttl-override"`
+	Proxied     *[]string
+
+	ttl     ttlPolicy
+	proxied proxyRules
+}
+
+// loadTagPolicies reads the tag policies of the config file, working out
+// the TTL policy and proxied rules each gives its zones.
+func loadTagPolicies() ([]tagPolicy, error) {
+	policies := make([]tagPolicy, 0)
+	if err := viper.UnmarshalKey("tag-policies", &policies); err != nil {
+		return nil, fmt.Errorf("Invalid tag policies: %s", err)
+	}
+
+	for i := range policies {
+		p := &policies[i]
+		if len(p.Tags) == 0 {
+			return nil, fmt.Errorf("Tag policy %d has no tags", i+1)
+		}
+
+		min := viper.GetInt("min-ttl")
+		if p.MinTTL != nil && !flagChanged("min-ttl") {
+			min = *p.MinTTL
+		}
+		overrides := viper.GetStringSlice("ttl-override")
+		if p.TTLOverride != nil && !flagChanged("ttl-override") {
+			overrides = *p.TTLOverride
+		}
+		rules := viper.GetStringSlice("proxied")
+		if p.Proxied != nil && !flagChanged("proxied") {
+			rules = *p.Proxied
+		}
+
+		var err error
+		if p.ttl, err = parseTTLPolicy(min, overrides); err != nil {
+			return nil, fmt.Errorf("Tag policy %d: %s", i+1, err)
+		}
+		if p.proxied, err = parseProxyRules(rules); err != nil {
+			return nil, fmt.Errorf("Tag policy %d: %s", i+1, err)
+		}
+	}
+
+	return policies, nil
+}
+
+// matches reports whether a hosted zone's tags carry all tags of a policy.
+func (p tagPolicy) matches(tags map[string]string) bool {
+	for k, v := range p.Tags {
+		if t, ok := tags[strings.ToLower(k)]; !ok || t != v {
+			return false
+		}
+	}
+	return true
+}
+
+// zonePolicies returns the TTL policy and proxied rules of a zone, those of
+// the first tag policy matching its hosted zone or else the run's.
+func zonePolicies(cfg *config, z *zone) (ttlPolicy, proxyRules, error) {
+	if len(cfg.tagPolicies) == 0 {
+		return cfg.ttl, cfg.proxied, nil
+	}
+
+	out, err := cfg.r53.ListTagsForResource(&route53.ListTagsForResourceInput{
+		ResourceType: aws.String("hostedzone"),
+		ResourceId:   aws.String(strings.TrimPrefix(z.hostedZoneID, "/hostedzone/")),
+	})
+	if err != nil {
+		return cfg.ttl, cfg.proxied, fmt.Errorf("Unable to read the tags of hosted zone %s: %s", z.hostedZoneID, err)
+	}
+
+	tags := make(map[string]string)
+	if out.ResourceTagSet != nil {
+		for _, t := range out.ResourceTagSet.Tags {
+			tags[strings.ToLower(aws.StringValue(t.Key))] = aws.StringValue(t.Value)
+		}
+	}
+
+	for _, p := range cfg.tagPolicies {
+		if p.matches(tags) {
+			return p.ttl, p.proxied, nil
+		}
+	}
+
+	return cfg.ttl, cfg.proxied, nil
+}
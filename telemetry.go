@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().Bool("telemetry", false, "Send anonymous usage statistics (command, duration and error class) to --telemetry-url")
+	viper.BindPFlag("telemetry", rootCmd.PersistentFlags().Lookup("telemetry"))
+
+	rootCmd.PersistentFlags().Bool("no-telemetry", false, "Never send usage statistics, overriding --telemetry")
+	viper.BindPFlag("no-telemetry", rootCmd.PersistentFlags().Lookup("no-telemetry"))
+
+	rootCmd.PersistentFlags().String("telemetry-url", "", "Endpoint usage statistics are posted to as JSON")
+	viper.BindPFlag("telemetry-url", rootCmd.PersistentFlags().Lookup("telemetry-url"))
+
+	telemetryCmd.AddCommand(telemetryStatusCmd)
+	rootCmd.AddCommand(telemetryCmd)
+}
+
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry",
+	Short: "Inspect the anonymous usage statistics setting",
+}
+
+var telemetryStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether usage statistics are sent and what they contain",
+	Args:  cobra.NoArgs,
+	Run:   doTelemetryStatus,
+}
+
+// usageEvent is what is sent about a run. It never holds zone names,
+// record data, credentials or error messages.
+type usageEvent struct {
+	Command    string  `json:"command"`
+	OS         string  `json:"os"`
+	Arch       string  `json:"arch"`
+	Seconds    float64 `json:"seconds"`
+	Success    bool    `json:"success"`
+	ErrorClass string  `json:"error_class,omitempty"`
+}
+
+// usage holds the command being run, set before it starts.
+var usage struct {
+	command string
+	started time.Time
+	sent    bool
+}
+
+// telemetryEnabled tells whether usage statistics are sent, and why not.
+func telemetryEnabled() (bool, string) {
+	switch {
+	case viper.GetBool("no-telemetry"):
+		return false, "disabled by no-telemetry"
+	case !viper.GetBool("telemetry"):
+		return false, "not enabled, telemetry is opt-in"
+	case viper.GetString("telemetry-url") == "":
+		return false, "enabled but no telemetry-url is configured"
+	}
+
+	return true, "enabled, sending to " + viper.GetString("telemetry-url")
+}
+
+// startUsage records the command about to run.
+func startUsage(cmd *cobra.Command, args []string) {
+	usage.command = cmd.CommandPath()
+	usage.started = time.Now()
+}
+
+// errorClass reduces an error to a class that says nothing about the zones
+// involved: the AWS error code, or otherwise the phase the run failed in.
+func errorClass(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return "aws " + aerr.Code()
+	}
+
+	return status.phase()
+}
+
+// reportUsage sends the usage event of the run once, if enabled. Failing to
+// send is silent, statistics must never get in the way of a run.
+func reportUsage(err error) {
+	if usage.command == "" || usage.sent {
+		return
+	}
+	usage.sent = true
+
+	if ok, _ := telemetryEnabled(); !ok {
+		return
+	}
+
+	event := usageEvent{
+		Command: usage.command,
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		Seconds: time.Since(usage.started).Seconds(),
+		Success: err == nil,
+	}
+	if err != nil {
+		event.ErrorClass = errorClass(err)
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Post(viper.GetString("telemetry-url"), "application/json", bytes.NewReader(payload))
+	if err == nil {
+		resp.Body.Close()
+	}
+}
+
+func doTelemetryStatus(cmd *cobra.Command, args []string) {
+	_, reason := telemetryEnabled()
+	fmt.Printf("Telemetry: %s\n", reason)
+	fmt.Println("When enabled each run sends the command, OS, architecture, duration, success")
+	fmt.Println("and the AWS error code or phase of a failure. Zone names, records, credentials")
+	fmt.Println("and error messages are never sent.")
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	configSetting("transforms", false)
+}
+
+// transform is a rule from the transforms section of the config file,
+// rewriting route53 records before they are compared with or created in
+// cloudflare. Name and Value are regular expressions, Rename and Replace
+// their replacements which may refer to groups as $1.
+//
+//	transforms:
+//	  - name: ^(.*)\.legacy$
+//	    rename: $1
+//	  - type: CNAME
+//	    value: ^old-lb-123\.us-east-1\.elb\.amazonaws\.com\.?$
+//	    replace: new-lb-456.us-east-1.elb.amazonaws.com.
+//	    ttl: 60
+//	  - name: ^api$
+//	    type: CNAME
+//	    value: .*
+//	    replace: "{{ssm:///dns/prod/api-target}}"
+//
+// Replacements may hold placeholders read from AWS, see interpolateRecords.
+type transform struct {
+	// Name matches the name relative to the zone, "@" for the apex. All
+	// names match when empty.
+	Name   string
+	Rename string
+
+	// Type limits the rule to one record type
+	Type string
+
+	Value   string
+	Replace string
+
+	// TTL overrides the TTL of matching records when set
+	TTL int
+
+	name  *regexp.Regexp
+	value *regexp.Regexp
+}
+
+// loadTransforms reads and compiles the transforms of the config file.
+func loadTransforms() ([]transform, error) {
+	transforms := make([]transform, 0)
+	if err := viper.UnmarshalKey("transforms", &transforms); err != nil {
+		return nil, fmt.Errorf("Invalid transforms: %s", err)
+	}
+
+	for i := range transforms {
+		t := &transforms[i]
+		t.Type = strings.ToUpper(t.Type)
+
+		var err error
+		if t.name, err = regexp.Compile(t.Name); err != nil {
+			return nil, fmt.Errorf("Invalid name pattern in transform %d: %s", i+1, err)
+		}
+		if t.value, err = regexp.Compile(t.Value); err != nil {
+			return nil, fmt.Errorf("Invalid value pattern in transform %d: %s", i+1, err)
+		}
+		if t.Replace != "" && t.Value == "" {
+			return nil, fmt.Errorf("Transform %d replaces values without a value pattern", i+1)
+		}
+		if t.TTL < 0 {
+			return nil, fmt.Errorf("Invalid TTL in transform %d", i+1)
+		}
+	}
+
+	return transforms, nil
+}
+
+// applyTransforms rewrites the route53 records of a zone, describing each
+// change in the zone's transformed list. Every rule applies in turn to the
+// result of the ones before it.
+func applyTransforms(transforms []transform, z *zone) {
+	for i, r := range z.awsRecordSet {
+		before := fmt.Sprintf("%s %s %d %s", r.Name, r.Type, r.TTL, strings.Join(r.Value, ", "))
+
+		for _, t := range transforms {
+			rel := relativeName(r.Name, z.apex())
+			if (t.Type != "" && t.Type != r.Type) || !t.name.MatchString(rel) {
+				continue
+			}
+
+			if t.Rename != "" {
+				rel = t.name.ReplaceAllString(rel, t.Rename)
+				if rel == "@" {
+					r.Name = strings.TrimSuffix(z.apex(), ".") + "."
+				} else {
+					r.Name = rel + "." + strings.TrimSuffix(z.apex(), ".") + "."
+				}
+			}
+
+			if t.Value != "" {
+				values := make([]string, 0, len(r.Value))
+				for _, v := range r.Value {
+					if t.value.MatchString(v) {
+						v = t.value.ReplaceAllString(v, t.Replace)
+					}
+					values = append(values, v)
+				}
+				r.Value = values
+			}
+
+			if t.TTL > 0 {
+				r.TTL = t.TTL
+			}
+		}
+
+		after := fmt.Sprintf("%s %s %d %s", r.Name, r.Type, r.TTL, strings.Join(r.Value, ", "))
+		if after != before {
+			z.transformed = append(z.transformed, before+" -> "+after)
+			z.awsRecordSet[i] = r
+		}
+	}
+}
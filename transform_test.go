@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestApplyTransforms(t *testing.T) {
+	viper.Set("transforms", []map[string]interface{}{
+		{"name": `^(.*)\.legacy$`, "rename": "$1"},
+		{"type": "cname", "value": `^old-lb\.example\.net\.?$`, "replace": "new-lb.example.net.", "ttl": 60},
+		{"name": "^www$", "rename": "@"},
+	})
+	defer viper.Set("transforms", nil)
+
+	transforms, err := loadTransforms()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	z := &zone{name: "example.com.", awsRecordSet: []record{
+		{Name: "api.legacy.example.com.", Type: "A", TTL: 300, Value: []string{"192.0.2.1"}},
+		{Name: "lb.example.com.", Type: "CNAME", TTL: 300, Value: []string{"old-lb.example.net."}},
+		{Name: "lb.example.com.", Type: "TXT", TTL: 300, Value: []string{`"old-lb.example.net."`}},
+		{Name: "www.example.com.", Type: "A", TTL: 300, Value: []string{"192.0.2.2"}},
+		{Name: "mail.example.com.", Type: "MX", TTL: 300, Value: []string{"10 mx.example.com."}},
+	}}
+	applyTransforms(transforms, z)
+
+	want := []record{
+		{Name: "api.example.com.", Type: "A", TTL: 300, Value: []string{"192.0.2.1"}},
+		{Name: "lb.example.com.", Type: "CNAME", TTL: 60, Value: []string{"new-lb.example.net."}},
+		{Name: "lb.example.com.", Type: "TXT", TTL: 300, Value: []string{`"old-lb.example.net."`}},
+		{Name: "example.com.", Type: "A", TTL: 300, Value: []string{"192.0.2.2"}},
+		{Name: "mail.example.com.", Type: "MX", TTL: 300, Value: []string{"10 mx.example.com."}},
+	}
+	if !reflect.DeepEqual(z.awsRecordSet, want) {
+		t.Errorf("got %+v, want %+v", z.awsRecordSet, want)
+	}
+
+	transformed := []string{
+		"api.legacy.example.com. A 300 192.0.2.1 -> api.example.com. A 300 192.0.2.1",
+		"lb.example.com. CNAME 300 old-lb.example.net. -> lb.example.com. CNAME 60 new-lb.example.net.",
+		"www.example.com. A 300 192.0.2.2 -> example.com. A 300 192.0.2.2",
+	}
+	if !reflect.DeepEqual(z.transformed, transformed) {
+		t.Errorf("got transformed %q, want %q", z.transformed, transformed)
+	}
+}
+
+func TestLoadTransformsInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform map[string]interface{}
+	}{
+		{"bad name pattern", map[string]interface{}{"name": "(", "rename": "x"}},
+		{"bad value pattern", map[string]interface{}{"value": "[", "replace": "x"}},
+		{"replace without value", map[string]interface{}{"replace": "x"}},
+		{"negative TTL", map[string]interface{}{"ttl": -1}},
+	}
+
+	defer viper.Set("transforms", nil)
+	for _, tt := range tests {
+		viper.Set("transforms", []map[string]interface{}{tt.transform})
+		if _, err := loadTransforms(); err == nil {
+			t.Errorf("%s: loaded, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// autoTTL is the TTL cloudflare uses for "automatic".
+const autoTTL = 1
+
+// autoTTLSeconds is the TTL cloudflare serves automatic TTL records with,
+// used where a real TTL is needed.
+const autoTTLSeconds = 300
+
+// ttlPolicy maps route53 TTLs to the TTLs records get in cloudflare.
+type ttlPolicy struct {
+	// min raises lower TTLs, cloudflare rejects TTLs below its plan's
+	// minimum
+	min int
+
+	// overrides set the TTL of every record of a type
+	overrides map[string]int
+}
+
+// parseTTLPolicy builds a TTL policy from a minimum and TYPE=SECONDS
+// overrides, where SECONDS may be "auto".
+func parseTTLPolicy(min int, overrides []string) (ttlPolicy, error) {
+	p := ttlPolicy{min: min, overrides: make(map[string]int)}
+	if min < 0 {
+		return p, fmt.Errorf("Invalid minimum TTL %d", min)
+	}
+
+	for _, o := range overrides {
+		parts := strings.SplitN(o, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return p, fmt.Errorf("Invalid TTL override '%s', expected TYPE=SECONDS", o)
+		}
+
+		ttl := autoTTL
+		if !strings.EqualFold(parts[1], "auto") {
+			n, err := strconv.Atoi(parts[1])
+			if err != nil || n < 1 {
+				return p, fmt.Errorf("Invalid TTL override '%s', expected TYPE=SECONDS", o)
+			}
+			ttl = n
+		}
+
+		p.overrides[strings.ToUpper(parts[0])] = ttl
+	}
+
+	return p, nil
+}
+
+// apply returns the cloudflare TTL of a record.
+func (p ttlPolicy) apply(typ string, ttl int) int {
+	if o, ok := p.overrides[typ]; ok {
+		return o
+	}
+
+	if ttl != autoTTL && ttl < p.min {
+		return p.min
+	}
+
+	return ttl
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// txtChunkSize is the longest character-string a TXT record can hold, longer
+// values are split into several strings.
+const txtChunkSize = 255
+
+// parseTXT returns the text of a TXT value. Route53 values are one or more
+// quoted strings, which are unquoted and joined. Route53 escapes bytes
+// outside printable ASCII as a backslash and three octal digits. Unquoted
+// values, as cloudflare stores them, are returned as is.
+func parseTXT(value string) string {
+	return unquoteTXT(value, 8)
+}
+
+// parseZoneTXT returns the text of a TXT value of a zone file, where the
+// \DDD escapes of RFC 1035 are decimal.
+func parseZoneTXT(value string) string {
+	return unquoteTXT(value, 10)
+}
+
+// unquoteTXT unquotes and joins the character-strings of a TXT value with
+// escapes of three digits in the given base.
+func unquoteTXT(value string, base int) string {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, `"`) {
+		return value
+	}
+
+	b := &strings.Builder{}
+	quoted := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && isByteEscape(value[i+1:], base):
+			n, _ := strconv.ParseUint(value[i+1:i+4], base, 8)
+			b.WriteByte(byte(n))
+			i += 3
+		case c == '\\' && i+1 < len(value):
+			i++
+			b.WriteByte(value[i])
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// isByteEscape tells whether s starts with the three digits in the given
+// base of an escaped byte.
+func isByteEscape(s string, base int) bool {
+	if len(s) < 3 {
+		return false
+	}
+
+	for i := 0; i < 3; i++ {
+		if s[i] < '0' || s[i] >= '0'+byte(base) {
+			return false
+		}
+	}
+
+	n, err := strconv.ParseUint(s[:3], base, 8)
+	return err == nil && n <= 255
+}
+
+// quoteTXT renders text as quoted character-strings of at most 255 bytes,
+// the form route53 expects. Bytes outside printable ASCII are escaped so the
+// value round-trips byte for byte.
+func quoteTXT(text string) string {
+	return quoteStrings(text, "\\%03o")
+}
+
+// quoteZoneTXT renders text as quoteTXT does, with the decimal escapes of
+// a zone file.
+func quoteZoneTXT(text string) string {
+	return quoteStrings(text, "\\%03d")
+}
+
+// quoteStrings renders text as quoted character-strings, escaping bytes
+// outside printable ASCII with the escape format.
+func quoteStrings(text, escape string) string {
+	chunks := make([]string, 0, len(text)/txtChunkSize+1)
+	for {
+		n := len(text)
+		if n > txtChunkSize {
+			n = txtChunkSize
+		}
+
+		chunk := &strings.Builder{}
+		for i := 0; i < n; i++ {
+			c := text[i]
+			switch {
+			case c == '\\' || c == '"':
+				chunk.WriteByte('\\')
+				chunk.WriteByte(c)
+			case c < ' ' || c > '~':
+				fmt.Fprintf(chunk, escape, c)
+			default:
+				chunk.WriteByte(c)
+			}
+		}
+		chunks = append(chunks, `"`+chunk.String()+`"`)
+
+		text = text[n:]
+		if text == "" {
+			break
+		}
+	}
+
+	return strings.Join(chunks, " ")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+var txtTests = []struct {
+	name    string
+	route53 string
+	text    string
+}{
+	{"plain", `"v=spf1 -all"`, "v=spf1 -all"},
+	{"quotes and backslashes", `"say \"hi\" \\ bye"`, `say "hi" \ bye`},
+	{"octal escapes", `"caf\303\251"`, "café"},
+	{"control byte", `"a\011b"`, "a\tb"},
+}
+
+func TestParseTXT(t *testing.T) {
+	for _, tt := range txtTests {
+		if got := parseTXT(tt.route53); got != tt.text {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.text)
+		}
+	}
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{`"part one " "part two"`, "part one part two"},
+		{"bare cloudflare text", "bare cloudflare text"},
+		{`  "padded"  `, "padded"},
+		{`"not \9 an escape"`, "not 9 an escape"},
+		{`"\400 is too large"`, "400 is too large"},
+	}
+	for _, tt := range tests {
+		if got := parseTXT(tt.value); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteTXT(t *testing.T) {
+	for _, tt := range txtTests {
+		if got := quoteTXT(tt.text); got != tt.route53 {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.route53)
+		}
+	}
+
+	long := strings.Repeat("a", txtChunkSize) + "b"
+	if got, want := quoteTXT(long), `"`+strings.Repeat("a", txtChunkSize)+`" "b"`; got != want {
+		t.Errorf("long text: got %q, want %q", got, want)
+	}
+	if got := quoteTXT(""); got != `""` {
+		t.Errorf("empty text: got %q, want %q", got, `""`)
+	}
+}
+
+func TestZoneTXT(t *testing.T) {
+	tests := []struct {
+		zone string
+		text string
+	}{
+		{`"caf\195\169"`, "café"},
+		{`"a\009b"`, "a\tb"},
+		{`"say \"hi\""`, `say "hi"`},
+	}
+
+	for _, tt := range tests {
+		if got := parseZoneTXT(tt.zone); got != tt.text {
+			t.Errorf("parse %q: got %q, want %q", tt.zone, got, tt.text)
+		}
+		if got := quoteZoneTXT(tt.text); got != tt.zone {
+			t.Errorf("quote %q: got %q, want %q", tt.text, got, tt.zone)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// findProblems reports duplicate values, CNAME records sharing their name
+// with other records and names that shadow a wildcard, in the records of
+// one provider. Values are compared normalized with the rules.
+func findProblems(records []record, apex string, rules canonicalRules) []string {
+	problems := make([]string, 0)
+
+	types := make(map[string]map[string]bool)
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, r := range records {
+		name := normalizeName(r.Name)
+		if types[name] == nil {
+			types[name] = make(map[string]bool)
+			names = append(names, name)
+		}
+		types[name][r.Type] = true
+
+		for _, v := range r.Value {
+			k := recordKey(r.Name, r.Type) + " " + normalizeValue(rules, r.Type, v)
+			if seen[k] {
+				problems = append(problems, fmt.Sprintf("%s %s %s: duplicate value", name, r.Type, v))
+			}
+			seen[k] = true
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		// the apex CNAME of a converted alias is flattened by cloudflare
+		if types[name]["CNAME"] && len(types[name]) > 1 && name != normalizeName(apex) {
+			others := make([]string, 0)
+			for t := range types[name] {
+				if t != "CNAME" {
+					others = append(others, t)
+				}
+			}
+			sort.Strings(others)
+			problems = append(problems, fmt.Sprintf("%s: CNAME shares its name with %s", name, strings.Join(others, ", ")))
+		}
+	}
+
+	// a name that exists stops a wildcard above it from answering, for
+	// every type, which only matters for the types it doesn't have itself.
+	// Names further down aren't matched by the wildcard either way.
+	for _, wildcard := range names {
+		if !strings.HasPrefix(wildcard, "*.") {
+			continue
+		}
+		parent := strings.TrimPrefix(wildcard, "*.")
+
+		for _, name := range names {
+			if name == parent || !inZone(name, parent) || !inZone(name, apex) {
+				continue
+			}
+			if label := strings.TrimSuffix(name, "."+parent); label == "*" || strings.Contains(label, ".") {
+				continue
+			}
+
+			missing := make([]string, 0)
+			for t := range types[wildcard] {
+				if !types[name][t] && !types[name]["CNAME"] {
+					missing = append(missing, t)
+				}
+			}
+			if len(missing) > 0 {
+				sort.Strings(missing)
+				problems = append(problems, fmt.Sprintf("%s: shadows %s, no %s answer", name, wildcard, strings.Join(missing, ", ")))
+			}
+		}
+	}
+
+	return problems
+}
+
+// validateZone records the problems found in the records of both providers.
+func validateZone(z *zone) {
+	for _, p := range findProblems(z.awsRecordSet, z.apex(), z.canonical) {
+		z.problems = append(z.problems, "route53 "+p)
+	}
+	for _, p := range findProblems(z.cfRecordSet, z.apex(), z.canonical) {
+		z.problems = append(z.problems, "cloudflare "+p)
+	}
+}
+
+// checkPlan checks the records cloudflare would hold after a plan against
+// the rules of DNS, so an invalid plan fails as a whole before the API
+// rejects its records one by one.
+func checkPlan(z *zone, plan []change) []string {
+	errs := make([]string, 0)
+
+	types := make(map[string]map[string]int)
+	add := func(name, typ string) {
+		name = normalizeName(name)
+		if types[name] == nil {
+			types[name] = make(map[string]int)
+		}
+		types[name][typ]++
+	}
+	for _, r := range z.cfRecordSet {
+		add(r.Name, r.Type)
+	}
+
+	for _, c := range plan {
+		rr := c.Record
+		if c.Action == "create" {
+			add(rr.Name, rr.Type)
+		}
+
+		target := ""
+		switch rr.Type {
+		case "A":
+			if ip := net.ParseIP(rr.Content); ip == nil || ip.To4() == nil {
+				errs = append(errs, fmt.Sprintf("%s A: '%s' is not an IPv4 address", rr.Name, rr.Content))
+			}
+		case "AAAA":
+			if err := validAAAA(rr.Content); err != nil {
+				errs = append(errs, fmt.Sprintf("%s AAAA: '%s' is not an IPv6 address", rr.Name, rr.Content))
+			}
+		case "CNAME", "NS", "PTR":
+			target = rr.Content
+		case "MX":
+			// a null MX, RFC 7505, says the domain takes no mail
+			if rr.Content != "." {
+				target = rr.Content
+			}
+		case "SRV":
+			if data, ok := rr.Data.(map[string]interface{}); ok {
+				target, _ = data["target"].(string)
+			}
+			if target == "." {
+				target = ""
+			}
+		}
+		if target != "" && validHostname(target) != nil {
+			errs = append(errs, fmt.Sprintf("%s %s: target '%s' is not a fully qualified host name", rr.Name, rr.Type, target))
+		}
+	}
+
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		n := types[name]["CNAME"]
+		if n == 0 {
+			continue
+		}
+		if n > 1 {
+			errs = append(errs, fmt.Sprintf("%s: %d CNAME records, a name can only have one", name, n))
+		}
+		others := make([]string, 0)
+		for t := range types[name] {
+			if t != "CNAME" {
+				others = append(others, t)
+			}
+		}
+		// apex aliases become an apex CNAME cloudflare flattens, it may
+		// sit beside the apex's other records
+		if len(others) > 0 && name != normalizeName(z.apex()) {
+			sort.Strings(others)
+			errs = append(errs, fmt.Sprintf("%s: CNAME can't coexist with %s, remove one of them from cloudflare or route53", name, strings.Join(others, ", ")))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestFindProblems(t *testing.T) {
+	tests := []struct {
+		name    string
+		records []record
+		want    []string
+	}{
+		{"duplicate value", []record{
+			{Name: "www.example.com.", Type: "A", Value: []string{"192.0.2.1", "192.0.2.1"}},
+		}, []string{"www.example.com A 192.0.2.1: duplicate value"}},
+		{"duplicate after normalizing", []record{
+			{Name: "example.com.", Type: "MX", Value: []string{"10 mx.example.com."}},
+			{Name: "example.com.", Type: "MX", Value: []string{"10 MX.example.com"}},
+		}, []string{"example.com MX 10 MX.example.com: duplicate value"}},
+		{"CNAME with other types", []record{
+			{Name: "www.example.com.", Type: "CNAME", Value: []string{"web.example.net."}},
+			{Name: "www.example.com.", Type: "TXT", Value: []string{`"x"`}},
+		}, []string{"www.example.com: CNAME shares its name with TXT"}},
+		{"flattened apex CNAME", []record{
+			{Name: "example.com.", Type: "CNAME", Value: []string{"lb.example.net."}},
+			{Name: "example.com.", Type: "MX", Value: []string{"10 mx.example.com."}},
+		}, []string{}},
+		{"shadowed wildcard type", []record{
+			{Name: "*.example.com.", Type: "A", Value: []string{"192.0.2.1"}},
+			{Name: "*.example.com.", Type: "AAAA", Value: []string{"2001:db8::1"}},
+			{Name: "api.example.com.", Type: "A", Value: []string{"192.0.2.2"}},
+		}, []string{"api.example.com: shadows *.example.com, no AAAA answer"}},
+		{"same types as the wildcard", []record{
+			{Name: "*.example.com.", Type: "A", Value: []string{"192.0.2.1"}},
+			{Name: "api.example.com.", Type: "A", Value: []string{"192.0.2.1"}},
+			{Name: "web.example.com.", Type: "A", Value: []string{"192.0.2.2"}},
+			{Name: "web.example.com.", Type: "TXT", Value: []string{`"x"`}},
+		}, []string{}},
+		{"CNAME under a wildcard", []record{
+			{Name: "*.example.com.", Type: "A", Value: []string{"192.0.2.1"}},
+			{Name: "cdn.example.com.", Type: "CNAME", Value: []string{"cdn.example.net."}},
+		}, []string{}},
+		{"name below the wildcard level", []record{
+			{Name: "*.example.com.", Type: "A", Value: []string{"192.0.2.1"}},
+			{Name: "_dmarc.mail.example.com.", Type: "TXT", Value: []string{`"v=DMARC1; p=none"`}},
+		}, []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := findProblems(tt.records, "example.com", nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPlan(t *testing.T) {
+	create := func(name, typ, content string) change {
+		return change{Action: "create", Record: cloudflare.DNSRecord{Name: name, Type: typ, Content: content}}
+	}
+
+	tests := []struct {
+		name     string
+		existing []record
+		plan     []change
+		want     []string
+	}{
+		{"valid records", nil, []change{
+			create("example.com", "A", "192.0.2.1"),
+			create("example.com", "AAAA", "2001:db8::1"),
+			create("www.example.com", "CNAME", "web.example.net"),
+			create("example.com", "MX", "."),
+		}, []string{}},
+		{"invalid addresses", nil, []change{
+			create("a.example.com", "A", "2001:db8::1"),
+			create("b.example.com", "AAAA", "192.0.2.1"),
+		}, []string{
+			"a.example.com A: '2001:db8::1' is not an IPv4 address",
+			"b.example.com AAAA: '192.0.2.1' is not an IPv6 address",
+		}},
+		{"invalid target", nil, []change{
+			create("example.com", "MX", "mail server"),
+		}, []string{"example.com MX: target 'mail server' is not a fully qualified host name"}},
+		{"SRV target", nil, []change{
+			{Action: "create", Record: cloudflare.DNSRecord{Name: "_sip._tcp.example.com", Type: "SRV", Data: map[string]interface{}{"target": "sip host"}}},
+			{Action: "create", Record: cloudflare.DNSRecord{Name: "_x._tcp.example.com", Type: "SRV", Data: map[string]interface{}{"target": "."}}},
+		}, []string{"_sip._tcp.example.com SRV: target 'sip host' is not a fully qualified host name"}},
+		{"CNAME beside cloudflare records", []record{
+			{Name: "www.example.com", Type: "TXT", Value: []string{"x"}},
+		}, []change{
+			create("www.example.com", "CNAME", "web.example.net"),
+		}, []string{"www.example.com: CNAME can't coexist with TXT, remove one of them from cloudflare or route53"}},
+		{"two CNAME records", nil, []change{
+			create("www.example.com", "CNAME", "a.example.net"),
+			create("www.example.com", "CNAME", "b.example.net"),
+		}, []string{"www.example.com: 2 CNAME records, a name can only have one"}},
+		{"apex CNAME", nil, []change{
+			create("example.com", "CNAME", "lb.example.net"),
+			create("example.com", "MX", "mx.example.com"),
+		}, []string{}},
+		{"updates add no records", []record{
+			{Name: "www.example.com", Type: "CNAME", Value: []string{"web.example.net"}},
+		}, []change{
+			{Action: "update", Record: cloudflare.DNSRecord{Name: "www.example.com", Type: "CNAME", Content: "web2.example.net"}},
+		}, []string{}},
+	}
+
+	for _, tt := range tests {
+		z := &zone{name: "example.com.", cfRecordSet: tt.existing}
+		if got := checkPlan(z, tt.plan); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.PersistentFlags().String("vault-addr", "", "Address of the Vault server to read credentials from (env VAULT_ADDR)")
+	viper.BindPFlag("vault-addr", rootCmd.PersistentFlags().Lookup("vault-addr"))
+
+	rootCmd.PersistentFlags().String("vault-token", "", "Vault token (env VAULT_TOKEN)")
+	viper.BindPFlag("vault-token", rootCmd.PersistentFlags().Lookup("vault-token"))
+
+	rootCmd.PersistentFlags().String("vault-role-id", "", "Role ID to sign in to Vault with AppRole instead of a token")
+	viper.BindPFlag("vault-role-id", rootCmd.PersistentFlags().Lookup("vault-role-id"))
+
+	rootCmd.PersistentFlags().String("vault-secret-id-file", "", "File holding the AppRole secret ID")
+	viper.BindPFlag("vault-secret-id-file", rootCmd.PersistentFlags().Lookup("vault-secret-id-file"))
+
+	rootCmd.PersistentFlags().String("vault-approle-path", "approle", "Mount path of the Vault AppRole auth method")
+	viper.BindPFlag("vault-approle-path", rootCmd.PersistentFlags().Lookup("vault-approle-path"))
+
+	rootCmd.PersistentFlags().String("vault-cf-path", "", "Vault path of the Cloudflare credentials, with email and key or token fields, such as secret/data/cfmigrate/cloudflare")
+	viper.BindPFlag("vault-cf-path", rootCmd.PersistentFlags().Lookup("vault-cf-path"))
+
+	rootCmd.PersistentFlags().String("vault-aws-path", "", "Vault path of the AWS credentials, with access_key, secret_key and optionally security_token fields, such as a KV secret or aws/creds/ROLE")
+	viper.BindPFlag("vault-aws-path", rootCmd.PersistentFlags().Lookup("vault-aws-path"))
+
+	configSetting("vault-token", true)
+	configSetting("vault-secret-id", true)
+}
+
+// vaultClient reads secrets from Vault over its HTTP API.
+type vaultClient struct {
+	addr   string
+	token  string
+	client *http.Client
+}
+
+// newVaultClient signs in to Vault with the token given, or with AppRole.
+func newVaultClient() (*vaultClient, error) {
+	v := &vaultClient{
+		addr:   strings.TrimSuffix(viper.GetString("vault-addr"), "/"),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+	if v.addr == "" {
+		return nil, errors.New("No Vault address supplied")
+	}
+
+	token, err := secretValue("vault-token")
+	if err != nil {
+		return nil, err
+	}
+	v.token = token
+
+	roleID := viper.GetString("vault-role-id")
+	if roleID == "" {
+		if v.token == "" {
+			return nil, errors.New("No Vault token or AppRole role ID supplied")
+		}
+		return v, nil
+	}
+
+	secretID, err := secretValue("vault-secret-id")
+	if err != nil {
+		return nil, err
+	}
+
+	var login struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	path := "auth/" + strings.Trim(viper.GetString("vault-approle-path"), "/") + "/login"
+	body := map[string]string{"role_id": roleID, "secret_id": secretID}
+	if err := v.do("POST", path, body, &login); err != nil {
+		return nil, fmt.Errorf("Unable to sign in to Vault with AppRole: %s", err)
+	}
+	v.token = login.Auth.ClientToken
+
+	return v, nil
+}
+
+// do makes a Vault API request, decoding the response into out.
+func (v *vaultClient) do(method, path string, in interface{}, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, v.addr+"/v1/"+strings.TrimPrefix(path, "/"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if v.token != "" {
+		req.Header.Set("X-Vault-Token", v.token)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failed struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(b, &failed)
+		return fmt.Errorf("%s: %s", resp.Status, strings.Join(failed.Errors, ", "))
+	}
+
+	return json.Unmarshal(b, out)
+}
+
+// read returns the fields of the secret at path, unwrapping the data of
+// KV version 2 secrets.
+func (v *vaultClient) read(path string) (map[string]string, error) {
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := v.do("GET", path, nil, &secret); err != nil {
+		return nil, fmt.Errorf("Unable to read %s from Vault: %s", path, err)
+	}
+
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = inner
+		}
+	}
+
+	fields := make(map[string]string)
+	for k, value := range data {
+		if s, ok := value.(string); ok {
+			fields[k] = s
+		}
+	}
+
+	return fields, nil
+}
+
+// vaultCredentials fills in the credentials not given otherwise from the
+// Vault paths configured.
+func vaultCredentials(cfg *config) error {
+	cfPath, awsPath := viper.GetString("vault-cf-path"), viper.GetString("vault-aws-path")
+	if cfPath == "" && awsPath == "" {
+		return nil
+	}
+
+	v, err := newVaultClient()
+	if err != nil {
+		return err
+	}
+
+	if cfPath != "" {
+		fields, err := v.read(cfPath)
+		if err != nil {
+			return err
+		}
+
+		if cfg.cfemail == "" {
+			cfg.cfemail = fields["email"]
+		}
+		if cfg.cfkey == "" && cfg.cftoken == "" {
+			cfg.cfkey, cfg.cftoken = fields["key"], fields["token"]
+		}
+	}
+
+	if awsPath != "" && cfg.awskey == "" {
+		fields, err := v.read(awsPath)
+		if err != nil {
+			return err
+		}
+
+		cfg.awskey, cfg.awssecret, cfg.awstoken = fields["access_key"], fields["secret_key"], fields["security_token"]
+	}
+
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/sts"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd)
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Print who the AWS and Cloudflare credentials belong to and what they may do",
+	Long: `Prints the AWS principal and the Cloudflare user or token the credentials
+map to, and checks the permissions a migration needs: reading Route53
+hosted zones and their record sets, and editing the DNS records of the
+Cloudflare zones. Without --domain every visible zone is checked.`,
+	Args: cobra.NoArgs,
+	Run:  doWhoami,
+}
+
+// credentialCheck is a check of what the credentials give access to,
+// returning what it found.
+type credentialCheck struct {
+	Name string
+	Run  func(cfg *config) (string, error)
+}
+
+// credentialChecks are run by whoami and doctor.
+var credentialChecks = []credentialCheck{
+	{"AWS principal", awsIdentity},
+	{"Route53 access", route53Access},
+	{"Cloudflare identity", cloudflareIdentity},
+	{"Cloudflare accounts", cloudflareAccounts},
+	{"Cloudflare DNS edit", cloudflareZoneAccess},
+}
+
+// awsIdentity describes the principal of the AWS credentials.
+func awsIdentity(cfg *config) (string, error) {
+	out, err := sts.New(cfg.session).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s in account %s", aws.StringValue(out.Arn), aws.StringValue(out.Account)), nil
+}
+
+// route53Access checks that the hosted zones and their record sets can be
+// read. Migrations only read route53.
+func route53Access(cfg *config) (string, error) {
+	zones, err := cfg.r53.ListHostedZones(&route53.ListHostedZonesInput{MaxItems: aws.String("100")})
+	if err != nil {
+		return "", fmt.Errorf("Unable to list hosted zones: %s", err)
+	}
+	if len(zones.HostedZones) == 0 {
+		return "no hosted zones visible", nil
+	}
+
+	id := zones.HostedZones[0].Id
+	if _, err := cfg.r53.ListResourceRecordSets(&route53.ListResourceRecordSetsInput{HostedZoneId: id, MaxItems: aws.String("1")}); err != nil {
+		return "", fmt.Errorf("Unable to read the record sets of %s: %s", *id, err)
+	}
+
+	more := ""
+	if aws.BoolValue(zones.IsTruncated) {
+		more = " or more"
+	}
+	return fmt.Sprintf("%d%s hosted zones readable", len(zones.HostedZones), more), nil
+}
+
+// cloudflareIdentity describes the cloudflare token or user.
+func cloudflareIdentity(cfg *config) (string, error) {
+	if cfg.cftoken == "" {
+		user, err := cfg.api.UserDetails()
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("user %s (%s)", user.Email, user.ID), nil
+	}
+
+	raw, err := cfg.api.Raw("GET", "/user/tokens/verify", nil)
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	if err := json.Unmarshal(raw, &token); err != nil {
+		return "", err
+	}
+	if token.Status != "active" {
+		return "", fmt.Errorf("API token %s is %s", token.ID, token.Status)
+	}
+
+	return fmt.Sprintf("API token %s, active", token.ID), nil
+}
+
+// cloudflareAccounts lists the cloudflare accounts the credentials reach.
+func cloudflareAccounts(cfg *config) (string, error) {
+	accounts, _, err := cfg.api.Accounts(cloudflare.PaginationOptions{PerPage: 50})
+	if err != nil {
+		return "", err
+	}
+
+	names := make([]string, 0, len(accounts))
+	for _, a := range accounts {
+		names = append(names, fmt.Sprintf("%s (%s)", a.Name, a.ID))
+	}
+
+	return strings.Join(names, ", "), nil
+}
+
+// cloudflareZoneAccess checks that the DNS records of the selected zones,
+// every visible zone when none is given, can be edited.
+func cloudflareZoneAccess(cfg *config) (string, error) {
+	zones, err := cfg.api.ListZones()
+	if err != nil {
+		return "", err
+	}
+
+	patterns := []string{cfg.domain}
+	switch {
+	case cfg.group != nil:
+		patterns = cfg.group.Domains
+	case cfg.subdomain != "":
+		patterns = []string{cfg.subdomain}
+	}
+
+	checked, listed := 0, 0
+	readOnly := make([]string, 0)
+	for _, z := range zones {
+		match := false
+		for _, p := range patterns {
+			if ok, _ := path.Match(normalizeName(p), normalizeName(z.Name)); ok {
+				match = true
+			}
+		}
+		if !match {
+			continue
+		}
+		checked++
+
+		// API tokens get no permissions listed on zones
+		if len(z.Permissions) == 0 {
+			continue
+		}
+		listed++
+
+		editable := false
+		for _, p := range z.Permissions {
+			if p == "#dns_records:edit" {
+				editable = true
+			}
+		}
+		if !editable {
+			readOnly = append(readOnly, z.Name)
+		}
+	}
+
+	if checked == 0 {
+		return "", fmt.Errorf("No cloudflare zone matches %s", strings.Join(patterns, ", "))
+	}
+	if len(readOnly) > 0 {
+		return "", fmt.Errorf("DNS records can't be edited in %s", strings.Join(readOnly, ", "))
+	}
+	if listed < checked {
+		return fmt.Sprintf("%d zones, permissions not listed for %d of them", checked, checked-listed), nil
+	}
+
+	return fmt.Sprintf("%d zones", checked), nil
+}
+
+func doWhoami(cmd *cobra.Command, args []string) {
+	// every zone is checked when none is selected
+	if viper.GetString("domain") == "" && viper.GetString("group") == "" {
+		viper.Set("domain", "*")
+	}
+
+	cfg, err := assembleConfig()
+	checkErr(err)
+
+	failed := 0
+	for _, c := range credentialChecks {
+		detail, err := c.Run(cfg)
+		if err != nil {
+			fmt.Printf("%s: FAILED: %s\n", c.Name, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: %s\n", c.Name, detail)
+	}
+
+	if failed > 0 {
+		checkErr(fmt.Errorf("%d credential checks failed", failed))
+	}
+}